@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Kinds of archive searched with --search-archives.
+const (
+	archiveTar = "tar"
+	archiveZip = "zip"
+)
+
+// archiveKind returns the kind of archive that filename names by its
+// extension, or "" if it isn't one. A tar archive may be compressed in any
+// format -z knows.
+func archiveKind(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tgz"),
+		strings.HasSuffix(name, ".tbz2"), strings.HasSuffix(name, ".txz"):
+		return archiveTar
+	}
+	for _, c := range compressions {
+		for _, ext := range c.exts {
+			if strings.HasSuffix(name, ".tar"+ext) {
+				return archiveTar
+			}
+		}
+	}
+	return ""
+}
+
+// searchArchive searches the regular files in the archive filename that
+// filter selects, each under the name archive!member. Members are checked
+// for binary content on their own, and decompressed with -z.
+func (s *searcher) searchArchive(filename string) *fileResult {
+	r := &fileResult{filename: filename}
+	var err error
+	if archiveKind(filename) == archiveZip {
+		err = s.searchZip(r)
+	} else {
+		err = s.searchTar(r)
+	}
+	if err != nil {
+		r.err = fmt.Errorf("read archive %s: %w", filename, err)
+	}
+	return r
+}
+
+func (s *searcher) searchTar(r *fileResult) error {
+	f, err := os.Open(r.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// the archive itself is decompressed even without -z
+	zr, err := openDecompressed(r.filename, f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := s.searchMember(r, hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *searcher) searchZip(r *fileResult) error {
+	zr, err := zip.OpenReader(r.filename)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = s.searchMember(r, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchMember searches the member name of the archive whose result is r,
+// reading it from content, and adds the outcome to r. Members left out by
+// filter are skipped.
+func (s *searcher) searchMember(r *fileResult, name string, content io.Reader) error {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	if s.filter.skipMember(name) {
+		return nil
+	}
+	start := time.Now()
+	var data []byte
+	var err error
+	if s.decompress {
+		data, err = readDecompressed(name, content)
+	} else {
+		data, err = io.ReadAll(content)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	m := &fileResult{filename: r.filename + "!" + name}
+	s.searchContent(m, data, start)
+	r.stdout.Write(m.stdout.Bytes())
+	r.stderr.Write(m.stderr.Bytes())
+	r.matched = r.matched || m.matched
+	r.stats.add(&m.stats)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Values of --binary-files.
+const (
+	binaryBinary       = "binary"        // report "Binary file X matches"
+	binaryText         = "text"          // search and print as if text (-a)
+	binaryWithoutMatch = "without-match" // treat as not matching (-I)
+)
+
+// binarySniffLen is how much of the input is inspected by isBinary.
+const binarySniffLen = 8 << 10
+
+// isBinary reports whether data looks like binary content: its first block
+// contains a NUL byte or is not valid UTF-8.
+func isBinary(data []byte) bool {
+	block := data[:min(len(data), binarySniffLen)]
+	if bytes.IndexByte(block, 0) >= 0 {
+		return true
+	}
+	if len(block) < len(data) {
+		// don't count a character cut in half by the block boundary
+		for i := len(block) - 1; i >= 0 && i > len(block)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(block[i]) {
+				if !utf8.FullRune(block[i:]) {
+					block = block[:i]
+				}
+				break
+			}
+		}
+	}
+	return !utf8.Valid(block)
+}
+
+// binaryOffset returns the offset at which isBinary found data to be
+// binary: its first NUL byte, or else its first invalid UTF-8 sequence.
+func binaryOffset(data []byte) int {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i
+	}
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression is a compressed file format that -z can search through.
+type compression struct {
+	// detect reports whether a file that starts with head is in the
+	// format, by its magic bytes
+	detect func(head []byte) bool
+	exts   []string
+	open   func(r io.Reader) (io.ReadCloser, error)
+}
+
+// magicLen is how much of a file detect is given: enough for the longest
+// magic, that of bzip2.
+const magicLen = 10
+
+// hasMagic returns a detect function for a format whose files start with
+// magic.
+func hasMagic(magic ...byte) func(head []byte) bool {
+	return func(head []byte) bool { return bytes.HasPrefix(head, magic) }
+}
+
+// isBzip2 reports whether head starts a bzip2 stream: "BZh", the block
+// size from '1' to '9', then the magic of the first block or, for an
+// empty stream, of its end.
+func isBzip2(head []byte) bool {
+	if len(head) < magicLen || string(head[:3]) != "BZh" || head[3] < '1' || head[3] > '9' {
+		return false
+	}
+	block := string(head[4:magicLen])
+	return block == "\x31\x41\x59\x26\x53\x59" || block == "\x17\x72\x45\x38\x50\x90"
+}
+
+var compressions = []compression{
+	{
+		detect: hasMagic(0x1f, 0x8b),
+		exts:   []string{".gz", ".tgz"},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		detect: isBzip2,
+		exts:   []string{".bz2", ".tbz2"},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		detect: hasMagic(0xfd, '7', 'z', 'X', 'Z', 0x00),
+		exts:   []string{".xz", ".txz"},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xr), nil
+		},
+	},
+	{
+		detect: hasMagic(0x28, 0xb5, 0x2f, 0xfd),
+		exts:   []string{".zst", ".zstd"},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+}
+
+// detectCompression returns the format of a file named name that starts
+// with head: the one whose magic bytes head starts with, or else the one
+// its extension names. It returns nil for an uncompressed file. byName
+// reports whether the extension names the format returned.
+func detectCompression(name string, head []byte) (c *compression, byName bool) {
+	for i := range compressions {
+		if compressions[i].detect(head) {
+			c = &compressions[i]
+			break
+		}
+	}
+	for i := range compressions {
+		for _, ext := range compressions[i].exts {
+			if strings.HasSuffix(name, ext) && (c == nil || c == &compressions[i]) {
+				return &compressions[i], true
+			}
+		}
+	}
+	return c, false
+}
+
+// readDecompressed reads r, the content of a file named name, decompressing
+// it if it is compressed.
+func readDecompressed(name string, r io.Reader) ([]byte, error) {
+	rc, err := openDecompressed(name, r)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// openDecompressed returns a reader of the decompressed content of r, or
+// of r itself if it isn't compressed.
+func openDecompressed(name string, r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// a short file just can't match the longer magic numbers
+	head, _ := br.Peek(magicLen)
+	c, byName := detectCompression(name, head)
+	if c == nil {
+		return io.NopCloser(br), nil
+	}
+	if byName {
+		return c.open(br)
+	}
+	// a plain file may happen to start with the magic bytes: if what
+	// follows them can't be decompressed, its own bytes are searched
+	rr := &replayReader{r: br, recording: true}
+	plain := io.NopCloser(io.MultiReader(&rr.buf, br))
+	rc, err := c.open(rr)
+	if err != nil {
+		return plain, nil
+	}
+	// some formats check their header only once data is read
+	dr := bufio.NewReader(rc)
+	if _, err := dr.Peek(1); err != nil && err != io.EOF {
+		rc.Close()
+		return plain, nil
+	}
+	rr.recording = false
+	rr.buf = bytes.Buffer{}
+	return readCloser{dr, rc}, nil
+}
+
+// replayReader reads r, keeping what it read while recording so that it
+// can be read again.
+type replayReader struct {
+	r         io.Reader
+	buf       bytes.Buffer
+	recording bool
+}
+
+func (rr *replayReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if rr.recording {
+		rr.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// readCloser reads from one reader and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const plainText = "hello\nworld\n"
+
+// bzip2Text is plainText compressed by bzip2, which has no writer in the
+// standard library or the dependencies.
+const bzip2Text = "BZh91AY&SY\x6b\x5f\xb1\xdd\x00\x00\x02\x41\x80\x00\x10\x06\x44\x90\x80\x20\x00\x31\x0c\x08\x21\xa3\x69\x08\x07\x23\xae\x87\x8b\xb9\x22\x9c\x28\x48\x35\xaf\xd8\xee\x80"
+
+// compress writes plainText through the writer w returns.
+func compress(t *testing.T, w func(io.Writer) (io.WriteCloser, error)) string {
+	t.Helper()
+	var b bytes.Buffer
+	wc, err := w(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(wc, plainText); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestReadDecompressed(t *testing.T) {
+	for _, tt := range []struct {
+		ext, data string
+	}{
+		{".gz", compress(t, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })},
+		{".bz2", bzip2Text},
+		{".xz", compress(t, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) })},
+		{".zst", compress(t, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) })},
+	} {
+		// detected by name and by magic bytes
+		for _, name := range []string{"f" + tt.ext, "f"} {
+			got, err := readDecompressed(name, strings.NewReader(tt.data))
+			if err != nil || string(got) != plainText {
+				t.Errorf("%s: got %q, %v, want %q", name, got, err, plainText)
+			}
+		}
+	}
+	// an empty bzip2 stream has no block
+	if got, err := readDecompressed("f", strings.NewReader("BZh9\x17\x72\x45\x38\x50\x90\x00\x00\x00\x00")); err != nil || len(got) != 0 {
+		t.Errorf("empty bzip2: got %q, %v", got, err)
+	}
+}
+
+func TestReadDecompressedPlain(t *testing.T) {
+	// text that starts like a compressed file is searched as it is
+	for _, text := range []string{
+		"BZh is a word\n",
+		"BZh91AY&SY, but not bzip2\n",
+		"\x1f\x8b then text\n",
+		"\x28\xb5\x2f\xfd then text\n",
+		strings.Repeat("long ", 2000),
+		"",
+	} {
+		got, err := readDecompressed("f", strings.NewReader(text))
+		if err != nil || string(got) != text {
+			t.Errorf("%q: got %q, %v", text, got, err)
+		}
+	}
+	// a file named as compressed must be
+	if _, err := readDecompressed("f.bz2", strings.NewReader("BZh is a word\n")); err == nil {
+		t.Error("f.bz2 holding text: no error")
+	}
+}
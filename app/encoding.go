@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Values of --encoding, after aliases are resolved.
+const (
+	encodingAuto        = "auto" // sniff a byte order mark, else raw bytes
+	encodingUTF8        = "utf-8"
+	encodingUTF16LE     = "utf-16le"
+	encodingUTF16BE     = "utf-16be"
+	encodingLatin1      = "latin1"
+	encodingWindows1252 = "windows-1252"
+)
+
+var encodingAliases = map[string]string{
+	"auto":         encodingAuto,
+	"utf-8":        encodingUTF8,
+	"utf8":         encodingUTF8,
+	"utf-16le":     encodingUTF16LE,
+	"utf16le":      encodingUTF16LE,
+	"utf-16be":     encodingUTF16BE,
+	"utf16be":      encodingUTF16BE,
+	"latin1":       encodingLatin1,
+	"latin-1":      encodingLatin1,
+	"iso-8859-1":   encodingLatin1,
+	"windows-1252": encodingWindows1252,
+	"cp1252":       encodingWindows1252,
+}
+
+// parseEncoding resolves the name given to --encoding.
+func parseEncoding(name string) (string, error) {
+	enc, ok := encodingAliases[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown --encoding %q, want utf-8, utf-16le, utf-16be, latin1, windows-1252 or auto", name)
+	}
+	return enc, nil
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// decodeText converts data from the encoding enc to UTF-8, dropping a
+// byte order mark. With encodingAuto the encoding is taken from the byte
+// order mark, and data without one is returned as is.
+func decodeText(data []byte, enc string) []byte {
+	if enc == encodingAuto {
+		switch {
+		case bytes.HasPrefix(data, bomUTF8):
+			enc = encodingUTF8
+		case bytes.HasPrefix(data, bomUTF16LE):
+			enc = encodingUTF16LE
+		case bytes.HasPrefix(data, bomUTF16BE):
+			enc = encodingUTF16BE
+		default:
+			return data
+		}
+	}
+	switch enc {
+	case encodingUTF8:
+		return bytes.TrimPrefix(data, bomUTF8)
+	case encodingUTF16LE:
+		return decodeUTF16(bytes.TrimPrefix(data, bomUTF16LE), false)
+	case encodingUTF16BE:
+		return decodeUTF16(bytes.TrimPrefix(data, bomUTF16BE), true)
+	case encodingLatin1:
+		return decodeSingleByte(data, nil)
+	case encodingWindows1252:
+		return decodeSingleByte(data, &windows1252)
+	}
+	return data
+}
+
+// decodeUTF16 decodes UTF-16 text. An odd trailing byte and unpaired
+// surrogates become U+FFFD.
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		lo, hi := data[2*i], data[2*i+1]
+		if bigEndian {
+			lo, hi = hi, lo
+		}
+		units[i] = uint16(lo) | uint16(hi)<<8
+	}
+	out := make([]byte, 0, len(data))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	if len(data)%2 != 0 {
+		out = utf8.AppendRune(out, utf8.RuneError)
+	}
+	return out
+}
+
+// decodeSingleByte decodes text in which each byte is a character: the
+// code point of the same value, except that high maps 0x80-0x9f.
+func decodeSingleByte(data []byte, high *[32]rune) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		r := rune(b)
+		if high != nil && b >= 0x80 && b < 0xa0 {
+			r = high[b-0x80]
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// windows1252 holds the characters of 0x80-0x9f in Windows-1252, which
+// otherwise matches Latin-1. The five unassigned bytes become U+FFFD.
+var windows1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
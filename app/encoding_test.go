@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestDecodeUTF16(t *testing.T) {
+	for _, tt := range []struct {
+		data      string
+		bigEndian bool
+		want      string
+	}{
+		{"a\x00b\x00", false, "ab"},
+		{"\x00a\x00b", true, "ab"},
+		{"\xe9\x00", false, "é"},
+		{"\xe5\x65", false, "日"},
+		{"\x65\xe5", true, "日"},
+		// a surrogate pair
+		{"\x3d\xd8\x00\xde", false, "😀"},
+		{"\xd8\x3d\xde\x00", true, "😀"},
+		// an unpaired surrogate
+		{"\x3d\xd8a\x00", false, "�a"},
+		{"\x00\xdea\x00", false, "�a"},
+		// an odd trailing byte
+		{"a\x00b", false, "a�"},
+		{"x", true, "�"},
+		{"", false, ""},
+	} {
+		if got := string(decodeUTF16([]byte(tt.data), tt.bigEndian)); got != tt.want {
+			t.Errorf("%q (big endian %v): got %q, want %q", tt.data, tt.bigEndian, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	for _, tt := range []struct {
+		data, enc, want string
+	}{
+		{"plain \xff", encodingAuto, "plain \xff"},
+		{"\xef\xbb\xbfutf-8", encodingAuto, "utf-8"},
+		{"\xff\xfea\x00", encodingAuto, "a"},
+		{"\xfe\xff\x00a", encodingAuto, "a"},
+		{"a\x00", encodingUTF16LE, "a"},
+		{"\xef\xbb\xbfa", encodingUTF8, "a"},
+		{"caf\xe9 \x80", encodingLatin1, "café \u0080"},
+		{"caf\xe9 \x80 \x81", encodingWindows1252, "café € �"},
+	} {
+		if got := string(decodeText([]byte(tt.data), tt.enc)); got != tt.want {
+			t.Errorf("%q as %s: got %q, want %q", tt.data, tt.enc, got, tt.want)
+		}
+	}
+}
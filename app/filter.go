@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+type globRule struct {
+	re *regex.Regexp
+	// whole is set for globs containing a '/', which match the path
+	// relative to the search root rather than the base name
+	whole bool
+}
+
+// globSet matches a slash-separated path against any of several globs.
+type globSet []globRule
+
+func newGlobSet(globs []string) (globSet, error) {
+	var s globSet
+	for _, g := range globs {
+		re, err := compileGlob(strings.TrimPrefix(g, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		s = append(s, globRule{re: re, whole: strings.Contains(g, "/")})
+	}
+	return s, nil
+}
+
+func (s globSet) match(rel string) bool {
+	for _, g := range s {
+		name := rel
+		if !g.whole {
+			name = path.Base(rel)
+		}
+		if g.re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileFilter decides which entries found by a recursive walk are searched,
+// from --include, --exclude, --exclude-dir, -t and -T.
+type fileFilter struct {
+	include    globSet
+	exclude    globSet
+	excludeDir globSet
+	types      globSet
+	notTypes   globSet
+}
+
+func newFileFilter(opts *options) (*fileFilter, error) {
+	types, err := fileTypes(opts.typeAdd)
+	if err != nil {
+		return nil, err
+	}
+	typeGlobs := func(names []string) ([]string, error) {
+		var globs []string
+		for _, name := range names {
+			g, ok := types[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown file type %q (see --type-list)", name)
+			}
+			globs = append(globs, g...)
+		}
+		return globs, nil
+	}
+
+	f := &fileFilter{}
+	sets := []struct {
+		dst   *globSet
+		globs []string
+		types bool
+	}{
+		{&f.include, opts.include, false},
+		{&f.exclude, opts.exclude, false},
+		{&f.excludeDir, opts.excludeDir, false},
+		{&f.types, opts.types, true},
+		{&f.notTypes, opts.notTypes, true},
+	}
+	for _, s := range sets {
+		globs := s.globs
+		if s.types {
+			if globs, err = typeGlobs(globs); err != nil {
+				return nil, err
+			}
+		}
+		if *s.dst, err = newGlobSet(globs); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// skipDir reports whether the directory at rel, relative to the search
+// root, is left out of the walk.
+func (f *fileFilter) skipDir(rel string) bool {
+	return f.excludeDir.match(rel)
+}
+
+// skipFile reports whether the file at rel, relative to the search root,
+// is left out of the search.
+func (f *fileFilter) skipFile(rel string) bool {
+	if len(f.include) > 0 && !f.include.match(rel) {
+		return true
+	}
+	if f.exclude.match(rel) {
+		return true
+	}
+	if len(f.types) > 0 && !f.types.match(rel) {
+		return true
+	}
+	return f.notTypes.match(rel)
+}
+
+// skipArchive reports whether the archive at rel, relative to the search
+// root, is left out with --search-archives. Only --exclude applies to the
+// archive itself; the other filters select its members.
+func (f *fileFilter) skipArchive(rel string) bool {
+	return f.exclude.match(rel)
+}
+
+// skipMember reports whether the archive member at the slash-separated
+// path name is left out, as a file at that path would be.
+func (f *fileFilter) skipMember(name string) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if f.skipDir(dir) {
+			return true
+		}
+	}
+	return f.skipFile(name)
+}
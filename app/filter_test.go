@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestFilter returns the filter of the command line args, with a
+// pattern added.
+func newTestFilter(t *testing.T, args ...string) *fileFilter {
+	t.Helper()
+	opts, err := parseArgs(append(args, "-E", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := newFileFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFileFilter(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		// what is tested: "file", "dir" or "member"
+		kind, rel string
+		skip      bool
+	}{
+		{nil, "file", "a/b.c", false},
+		{[]string{"--include=*.go"}, "file", "a/main.go", false},
+		{[]string{"--include=*.go"}, "file", "a/main.c", true},
+		{[]string{"--include=*.go", "--include=*.c"}, "file", "main.c", false},
+		// a glob with a slash matches the whole path
+		{[]string{"--include=cmd/*.go"}, "file", "cmd/main.go", false},
+		{[]string{"--include=cmd/*.go"}, "file", "app/main.go", true},
+		{[]string{"--include=/cmd/*.go"}, "file", "cmd/main.go", false},
+		{[]string{"--exclude=*_test.go"}, "file", "a/x_test.go", true},
+		{[]string{"--exclude=*_test.go"}, "file", "a/x.go", false},
+		{[]string{"--include=*.go", "--exclude=x*"}, "file", "x.go", true},
+		// without a slash, the name at any depth
+		{[]string{"--exclude-dir=vendor"}, "dir", "a/vendor", true},
+		{[]string{"--exclude-dir=vendor"}, "dir", "a/vendors", false},
+		{[]string{"--exclude-dir=vendor"}, "dir", "vendor", true},
+		{[]string{"--exclude-dir=vendor"}, "file", "vendor", false},
+		{[]string{"--exclude-dir=**/gen"}, "dir", "a/b/gen", true},
+		{[]string{"-t", "go"}, "file", "main.go", false},
+		{[]string{"-t", "go"}, "file", "main.c", true},
+		{[]string{"-t", "make"}, "file", "sub/Makefile", false},
+		{[]string{"-T", "go"}, "file", "main.go", true},
+		{[]string{"-T", "go"}, "file", "main.c", false},
+		{[]string{"-t", "go", "-t", "c"}, "file", "x.h", false},
+		{[]string{"--type-add", "web:*.vue", "-t", "web"}, "file", "app.vue", false},
+		// members are filtered as files at their path in the archive
+		{[]string{"--exclude-dir=vendor"}, "member", "x/vendor/a.go", true},
+		{[]string{"--exclude-dir=vendor"}, "member", "vendor.go", false},
+		{[]string{"--exclude-dir=vendor"}, "member", "vendor/a.go", true},
+		{[]string{"--exclude-dir=a/b"}, "member", "a/b/c/d.go", true},
+		{[]string{"--exclude-dir=a/b"}, "member", "x/a/b/d.go", false},
+		{[]string{"-t", "go"}, "member", "a/b.txt", true},
+		{[]string{"--include=*.go"}, "member", "a/b.go", false},
+	} {
+		f := newTestFilter(t, tt.args...)
+		var got bool
+		switch tt.kind {
+		case "file":
+			got = f.skipFile(tt.rel)
+		case "dir":
+			got = f.skipDir(tt.rel)
+		case "member":
+			got = f.skipMember(tt.rel)
+		}
+		if got != tt.skip {
+			t.Errorf("%q, %s %s: got skip %v, want %v", tt.args, tt.kind, tt.rel, got, tt.skip)
+		}
+	}
+}
+
+func TestFileTypes(t *testing.T) {
+	types, err := fileTypes([]string{"go:*.tmpl", "web:*.vue,*.svelte", "web:*.astro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// --type-add appends to a type, without changing the defaults
+	if got, want := types["go"], []string{"*.go", "*.tmpl"}; !slices.Equal(got, want) {
+		t.Errorf("go: got %q, want %q", got, want)
+	}
+	if got, want := defaultTypes["go"], []string{"*.go"}; !slices.Equal(got, want) {
+		t.Errorf("default go: got %q, want %q", got, want)
+	}
+	if got, want := types["web"], []string{"*.vue", "*.svelte", "*.astro"}; !slices.Equal(got, want) {
+		t.Errorf("web: got %q, want %q", got, want)
+	}
+
+	for _, def := range []string{"go", ":*.go", "go:"} {
+		if _, err := fileTypes([]string{def}); err == nil {
+			t.Errorf("--type-add %q: no error", def)
+		}
+	}
+
+	opts, err := parseArgs([]string{"-t", "nope", "-E", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileFilter(opts); err == nil || !strings.Contains(err.Error(), `unknown file type "nope"`) {
+		t.Errorf("-t nope: got %v, want an unknown file type error", err)
+	}
+}
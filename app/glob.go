@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// compileGlob translates a shell glob into an anchored regex over
+// slash-separated paths. '*' and '?' do not cross '/', "**" does, and
+// "**/" may also match nothing, so "**/x" matches "x" at any depth.
+// Bracket classes are kept, with a leading '!' meaning negation.
+func compileGlob(glob string) (*regex.Regexp, error) {
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == 0 && i+2 < len(glob) {
+				// ']' first in the class is a literal
+				end = strings.IndexByte(glob[i+2:], ']') + 1
+			}
+			if end <= 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			writeLiteral(&b, glob[i])
+		default:
+			writeLiteral(&b, c)
+		}
+	}
+	b.WriteByte('$')
+	return regex.Compile(b.String())
+}
+
+// writeLiteral writes c so that the regex parser takes it literally.
+// Letters and digits are never escaped since \d, \w and \1 are special.
+func writeLiteral(b *strings.Builder, c byte) {
+	if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c >= 0x80) {
+		b.WriteByte('\\')
+	}
+	b.WriteByte(c)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// ignoreFiles are read in every directory of a recursive search, in
+// increasing order of precedence.
+var ignoreFiles = []string{".gitignore", ".ignore", ".mygrepignore"}
+
+type ignoreRule struct {
+	re      *regex.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher holds the rules of one directory and links to those of its
+// parent. Rules are matched against paths relative to base; base and the
+// paths passed to ignored are absolute.
+type ignoreMatcher struct {
+	parent *ignoreMatcher
+	base   string
+	rules  []ignoreRule
+}
+
+// ignored reports whether path is excluded. The deepest directory with a
+// matching rule decides, and within a directory the last matching rule
+// wins, so a later "!pattern" re-includes what an earlier one excluded.
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	for ; m != nil; m = m.parent {
+		rel, err := filepath.Rel(m.base, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for i := len(m.rules) - 1; i >= 0; i-- {
+			r := &m.rules[i]
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				return !r.negate
+			}
+		}
+	}
+	return false
+}
+
+// child returns the matcher for dir, whose ignore files are read on top of
+// the rules inherited from m. m itself is returned if dir has none.
+func (m *ignoreMatcher) child(dir string) *ignoreMatcher {
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name))...)
+	}
+	if len(rules) == 0 {
+		return m
+	}
+	return &ignoreMatcher{parent: m, base: dir, rules: rules}
+}
+
+// rootIgnoreMatcher returns the matcher for a search rooted at the absolute
+// directory root. When root is inside a git repository, the global excludes
+// file, the repository's .git/info/exclude and the ignore files of the
+// directories between the repository top and root apply as well.
+func rootIgnoreMatcher(root string) *ignoreMatcher {
+	var m *ignoreMatcher
+	// directories from root up to the repository top, if there is one
+	var dirs []string
+	top := ""
+	for dir := root; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			top = dir
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if top == "" {
+		return m.child(root)
+	}
+
+	var rules []ignoreRule
+	if f := globalExcludesFile(); f != "" {
+		rules = append(rules, readIgnoreFile(f)...)
+	}
+	rules = append(rules, readIgnoreFile(filepath.Join(top, ".git", "info", "exclude"))...)
+	if len(rules) > 0 {
+		m = &ignoreMatcher{base: top, rules: rules}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m = m.child(dirs[i])
+	}
+	return m
+}
+
+// globalExcludesFile returns git's core.excludesFile, or its default
+// location under the XDG config directory.
+func globalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		if f, err := os.Open(filepath.Join(home, ".gitconfig")); err == nil {
+			defer f.Close()
+			section := ""
+			sc := bufio.NewScanner(f)
+			for sc.Scan() {
+				line := strings.TrimSpace(sc.Text())
+				if strings.HasPrefix(line, "[") {
+					section = strings.ToLower(strings.Trim(line, "[]"))
+					continue
+				}
+				key, val, ok := strings.Cut(line, "=")
+				if ok && section == "core" && strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+					val = strings.Trim(strings.TrimSpace(val), `"`)
+					if strings.HasPrefix(val, "~/") {
+						val = filepath.Join(home, val[2:])
+					}
+					return val
+				}
+			}
+		}
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if home != "" {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// readIgnoreFile parses a gitignore-format file. A missing or unreadable
+// file has no rules.
+func readIgnoreFile(name string) []ignoreRule {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// a pattern with a slash is relative to the ignore file's directory;
+	// otherwise it matches a name at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	re, err := compileGlob(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// isHidden reports whether a directory entry name is a dotfile.
+func isHidden(name string) bool {
+	return len(name) > 1 && name[0] == '.' && name != ".."
+}
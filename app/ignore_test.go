@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	for _, tt := range []struct {
+		glob, path string
+		want       bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "app/main.go", false},
+		{"*.go", "main.goo", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"a?b", "a/b", false},
+		{"**/x", "x", true},
+		{"**/x", "a/b/x", true},
+		{"**/x", "ax", false},
+		{"a/**", "a/b/c", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a**", "ab/c", true},
+		{"[ab].c", "b.c", true},
+		{"[!ab].c", "b.c", false},
+		{"[!ab].c", "x.c", true},
+		{"[]a]", "]", true},
+		{"[a-c]*", "cat", true},
+		{"[", "[", true},
+		{"a.b", "axb", false},
+		{"a+(b)", "a+(b)", true},
+		{`\*`, "*", true},
+		{`\*`, "x", false},
+		{"\\1", "1", true},
+		{"é*", "été", true},
+	} {
+		re, err := compileGlob(tt.glob)
+		if err != nil {
+			t.Errorf("compileGlob(%q): %v", tt.glob, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("%q on %q: got %v, want %v", tt.glob, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnoreLine(t *testing.T) {
+	for _, tt := range []struct {
+		line            string
+		ok              bool
+		negate, dirOnly bool
+		matches, misses string
+	}{
+		{line: ""},
+		{line: "# comment"},
+		{line: "   "},
+		{line: "/"},
+		{line: "*.log", ok: true, matches: "a/b.log", misses: "b.log.txt"},
+		{line: "/build", ok: true, matches: "build", misses: "a/build"},
+		{line: "a/b", ok: true, matches: "a/b", misses: "x/a/b"},
+		{line: "dir/", ok: true, dirOnly: true, matches: "x/dir", misses: "dirx"},
+		{line: "!keep.log", ok: true, negate: true, matches: "keep.log", misses: "x.log"},
+		{line: `\!bang`, ok: true, matches: "!bang", misses: "bang"},
+		{line: `\#hash`, ok: true, matches: "#hash", misses: "hash"},
+		{line: "trail  ", ok: true, matches: "trail", misses: "trail "},
+		{line: `space\ `, ok: true, matches: "space ", misses: "space"},
+		{line: "crlf\r", ok: true, matches: "crlf", misses: "crlf\r"},
+		{line: "**/gen/*.go", ok: true, matches: "a/gen/x.go", misses: "a/gen/b/x.go"},
+	} {
+		r, ok := parseIgnoreLine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("%q: got ok %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if r.negate != tt.negate || r.dirOnly != tt.dirOnly {
+			t.Errorf("%q: got negate %v, dirOnly %v, want %v, %v", tt.line, r.negate, r.dirOnly, tt.negate, tt.dirOnly)
+		}
+		if !r.re.MatchString(tt.matches) {
+			t.Errorf("%q doesn't match %q", tt.line, tt.matches)
+		}
+		if r.re.MatchString(tt.misses) {
+			t.Errorf("%q matches %q", tt.line, tt.misses)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":        "*.log\n!keep.log\n/build\ndir/\n",
+		"sub/.ignore":       "!sub.log\nlocal.txt\n",
+		"sub/.mygrepignore": "keep.log\n",
+	} {
+		name = filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := rootIgnoreMatcher(root)
+	sub := m.child(filepath.Join(root, "sub"))
+	for _, tt := range []struct {
+		m     *ignoreMatcher
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{m, "a.log", false, true},
+		{m, "keep.log", false, false},
+		{m, "x/a.log", false, true},
+		{m, "build", true, true},
+		{m, "x/build", true, false},
+		{m, "dir", true, true},
+		{m, "dir", false, false},
+		{m, "x/dir", true, true},
+		{m, "local.txt", false, false},
+		{m, "main.go", false, false},
+		// a nested ignore file overrides its parents, and a later file in
+		// the same directory the earlier
+		{sub, "sub/a.log", false, true},
+		{sub, "sub/sub.log", false, false},
+		{sub, "sub/local.txt", false, true},
+		{sub, "sub/keep.log", false, true},
+		{sub, "sub/deep/local.txt", false, true},
+		{sub, "sub/main.go", false, false},
+	} {
+		if got := tt.m.ignored(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("%s (dir %v): got ignored %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
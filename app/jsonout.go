@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+)
+
+// The --json events follow ripgrep's JSON Lines schema: a begin and an end
+// event around the lines of each file with a match, match and context
+// events for the lines, and a closing summary. Matches also carry the spans
+// of their capturing groups and, with --replace, their replacement text.
+
+type jsonBegin struct {
+	Path jsonBytes `json:"path"`
+}
+
+type jsonEnd struct {
+	Path         jsonBytes `json:"path"`
+	BinaryOffset *int      `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonLine struct {
+	Path           jsonBytes      `json:"path"`
+	Lines          jsonBytes      `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int            `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonSubmatch struct {
+	Match       jsonBytes   `json:"match"`
+	Replacement *jsonBytes  `json:"replacement,omitempty"`
+	Start       int         `json:"start"`
+	End         int         `json:"end"`
+	Groups      []jsonGroup `json:"groups,omitempty"`
+}
+
+type jsonGroup struct {
+	Group int       `json:"group"`
+	Match jsonBytes `json:"match"`
+	Start int       `json:"start"`
+	End   int       `json:"end"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration `json:"elapsed_total"`
+	Stats        jsonStats    `json:"stats"`
+}
+
+type jsonStats struct {
+	Elapsed           jsonDuration `json:"elapsed"`
+	Searches          int          `json:"searches"`
+	SearchesWithMatch int          `json:"searches_with_match"`
+	BytesSearched     int64        `json:"bytes_searched"`
+	BytesPrinted      int64        `json:"bytes_printed"`
+	MatchedLines      int          `json:"matched_lines"`
+	Matches           int          `json:"matches"`
+}
+
+type jsonDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int    `json:"nanos"`
+	Human string `json:"human"`
+}
+
+func newJSONDuration(d time.Duration) jsonDuration {
+	return jsonDuration{
+		Secs:  int64(d / time.Second),
+		Nanos: int(d % time.Second),
+		Human: d.String(),
+	}
+}
+
+func newJSONStats(st *searchStats) jsonStats {
+	return jsonStats{
+		Elapsed:           newJSONDuration(st.elapsed),
+		Searches:          st.searches,
+		SearchesWithMatch: st.searchesWithMatch,
+		BytesSearched:     st.bytesSearched,
+		BytesPrinted:      st.bytesPrinted,
+		MatchedLines:      st.matchedLines,
+		Matches:           st.matches,
+	}
+}
+
+// jsonBytes is encoded as {"text": ...} when it is valid UTF-8 and as
+// {"bytes": <base64>} otherwise, since JSON strings can't hold raw bytes.
+type jsonBytes string
+
+func (b jsonBytes) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(b)) {
+		return json.Marshal(map[string]string{"text": string(b)})
+	}
+	return json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(b))})
+}
+
+// writeJSON writes one event as a line of JSON.
+func writeJSON(w *bytes.Buffer, typ string, data any) {
+	b, err := json.Marshal(struct {
+		Type string `json:"type"`
+		Data any    `json:"data"`
+	}{typ, data})
+	if err != nil {
+		// every event type marshals; this would be a programming error
+		panic(err)
+	}
+	w.Write(b)
+	w.WriteByte('\n')
+}
+
+// jsonLine writes a match or context event for l.
+func (p *printer) jsonLine(w *bytes.Buffer, filename string, l outputLine) {
+	text := l.text + l.eol
+	e := jsonLine{
+		Path:           jsonBytes(filename),
+		Lines:          jsonBytes(text),
+		LineNumber:     l.number,
+		AbsoluteOffset: l.offset,
+		Submatches:     []jsonSubmatch{},
+	}
+	for i, m := range l.matches {
+		sm := jsonSubmatch{Match: jsonBytes(text[m[0]:m[1]]), Start: m[0], End: m[1]}
+		if l.replacements != nil {
+			r := jsonBytes(l.replacements[i])
+			sm.Replacement = &r
+		}
+		for g := 1; 2*g+1 < len(m); g++ {
+			s, end := m[2*g], m[2*g+1]
+			if s < 0 {
+				continue
+			}
+			sm.Groups = append(sm.Groups, jsonGroup{Group: g, Match: jsonBytes(text[s:end]), Start: s, End: end})
+		}
+		e.Submatches = append(e.Submatches, sm)
+	}
+	typ := "match"
+	if l.context {
+		typ = "context"
+	}
+	writeJSON(w, typ, e)
+}
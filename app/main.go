@@ -1,119 +1,150 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+	"golang.org/x/term"
 )
 
 // Ensures gofmt doesn't remove the "bytes" import above (feel free to remove this!)
 var _ = bytes.ContainsAny
 
 // Usage: echo <input_text> | your_program.sh -E <pattern>
-//        or: your_program.sh -E <pattern> <filename>
+//        or: your_program.sh -E <pattern> <filename> (- for stdin)
 //        or: your_program.sh -r -E <pattern> <directory>
+//        or: your_program.sh -R -E <pattern> <directory> (follow symlinks)
+//        or: your_program.sh -r -E <pattern> (searches .)
 // Supports nested backreferences: groups numbered by opening paren position
+// The options are described in README.md.
 func main() {
-	// Parse flags
-	recursive := false
-	argIdx := 1
-	
-	// Check for -r flag
-	if len(os.Args) > 1 && os.Args[1] == "-r" {
-		recursive = true
-		argIdx = 2
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n%s\n", err, usage)
+		os.Exit(2)
 	}
-	
-	if len(os.Args) < argIdx+2 || os.Args[argIdx] != "-E" {
-		fmt.Fprintf(os.Stderr, "usage: mygrep [-r] -E <pattern> [filename|directory]\n")
+	recursive := opts.recursive
+
+	filter, err := newFileFilter(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(2)
 	}
+	if opts.typeList {
+		types, _ := fileTypes(opts.typeAdd)
+		printTypeList(os.Stdout, types)
+		os.Exit(0)
+	}
 
-	pattern := os.Args[argIdx+1]
+	re, err := regex.CompileOptions(opts.pattern, regex.Options{
+		MaxSteps:  opts.maxSteps,
+		Timeout:   opts.timeout,
+		Memoize:   opts.memoize,
+		MultiLine: opts.multiLine,
+		CRLF:      opts.crlf,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Like GNU grep, a recursive search with no operands searches the
+	// current directory rather than waiting on stdin
+	paths := opts.paths
+	if len(paths) == 0 && recursive {
+		paths = []string{"."}
+	}
+
+	// Stdin is read unless it is a terminal nobody will type into; "-"
+	// reads it regardless
+	if len(paths) == 0 && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "error: no input files, and stdin is a terminal (use - to read it)\n%s\n", usage)
+		os.Exit(2)
+	}
+	// Without operands stdin is only checked for a match, unless an option
+	// asks for output or changes how input is read; then it is searched as
+	// "-" is
+	if len(paths) == 0 && opts.searchesStdin() {
+		paths = []string{stdinName}
+	}
 
 	// Check if we have file/directory arguments
-	if len(os.Args) >= argIdx+3 {
-		paths := os.Args[argIdx+2:]
+	if len(paths) > 0 {
 		foundMatch := false
 		
-		// If recursive mode, collect all files from directories
-		var filesToProcess []string
-		if recursive {
-			for _, path := range paths {
-				err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
-					// Only process regular files
-					if !info.IsDir() {
-						filesToProcess = append(filesToProcess, filePath)
-					}
-					return nil
-				})
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "error: walking directory %s: %v\n", path, err)
-					os.Exit(2)
-				}
-			}
-		} else {
-			filesToProcess = paths
-		}
+		multipleFiles := len(paths) > 1 || recursive
 		
-		multipleFiles := len(filesToProcess) > 1 || recursive
+		// Errors on individual paths are reported and the search goes on
+		errs := &errorReporter{w: os.Stderr, quiet: opts.noMessages}
 		
-		for _, filename := range filesToProcess {
-			content, err := os.ReadFile(filename)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error: read file %s: %v\n", filename, err)
-				os.Exit(2)
-			}
-			
-			// Process file line by line
-			lines := strings.Split(string(content), "\n")
-			
-			for i, line := range lines {
-				// Skip empty last line from trailing newline (common case)
-				if i == len(lines)-1 && line == "" {
-					continue
-				}
-				
-				ok, err := matchPattern(line, pattern)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "error: %v\n", err)
-					os.Exit(2)
-				}
-				
-				if ok {
-					// Print with filename prefix if multiple files
-					if multipleFiles {
-						fmt.Printf("%s:%s\n", filename, line)
-					} else {
-						fmt.Println(line)
-					}
-					foundMatch = true
-				}
-			}
+		// Files are streamed from the walk to the workers as they are found
+		files := make(chan string, 256)
+		go func() {
+			walkFiles(paths, opts, filter, errs, files)
+			close(files)
+		}()
+		
+		// Files are read and matched by a pool of workers; each file's
+		// output is written in one piece.
+		out := bufio.NewWriter(os.Stdout)
+		start := time.Now()
+		var stats searchStats
+		s := newSearcher(opts, re, filter, multipleFiles)
+		search := s.searchFile
+		rewrite := opts.inPlace || opts.dryRun
+		if rewrite {
+			search = s.rewriteFile
+		}
+		searchFiles(files, opts.jobs, opts.unordered, search, func(r *fileResult) {
+			out.Write(r.stdout.Bytes())
+			if r.stderr.Len() > 0 || r.err != nil {
+				out.Flush()
+				os.Stderr.Write(r.stderr.Bytes())
+			}
+			if r.err != nil {
+				errs.report(r.filename, r.err)
+			}
+			foundMatch = foundMatch || r.matched
+			stats.add(&r.stats)
+		})
+		if rewrite {
+			out.Flush()
+			writeRewriteSummary(os.Stderr, &stats, opts.dryRun)
+		} else {
+			s.out.summary(out, &stats, time.Since(start))
 		}
+		out.Flush()
+		errs.summarize()
 		
+		// As in GNU grep, a match wins over errors elsewhere
 		if foundMatch {
 			os.Exit(0)
+		} else if errs.failed() {
+			os.Exit(2)
 		} else {
 			os.Exit(1)
 		}
 	}
 
-	// Stdin mode: read from stdin
+	// Stdin mode
 	input, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: read input text: %v\n", err)
 		os.Exit(2)
 	}
 
+	binary := opts.binaryFiles != binaryText && isBinary(input)
+	if binary && opts.binaryFiles == binaryWithoutMatch {
+		os.Exit(1)
+	}
+
 	// Stdin mode: just check for match
-	ok, err := matchPattern(string(input), pattern)
+	ok, err := re.TryMatchString(string(input))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(2)
@@ -122,880 +153,7 @@ func main() {
 	if !ok {
 		os.Exit(1)
 	}
-}
-
-func matchPattern(text, pattern string) (bool, error) {
-	// anchors
-	startAnchor := false
-	endAnchor := false
-	if len(pattern) > 0 && pattern[0] == '^' {
-		startAnchor = true
-		pattern = pattern[1:]
-	}
-	if len(pattern) > 0 && pattern[len(pattern)-1] == '$' {
-		
-		endAnchor = true
-		pattern = pattern[:len(pattern)-1]
-	}
-
-	if startAnchor && endAnchor {
-		pos, _, _, _ := matchHere(text, pattern, 0, map[int]string{}, map[int]bool{}, 1)
-		return pos == len(text), nil
-	}
-	if startAnchor {
-		pos, _, _, _ := matchHere(text, pattern, 0, map[int]string{}, map[int]bool{}, 1)
-		return pos >= 0, nil
-	}
-	if endAnchor {
-		for i := 0; i <= len(text); i++ {
-			pos, _, _, _ := matchHere(text, pattern, i, map[int]string{}, map[int]bool{}, 1)
-			if pos == len(text) {
-				return true, nil
-			}
-		}
-		return false, nil
-	}
-	for i := 0; i <= len(text); i++ {
-		if pos, _, _, _ := matchHere(text, pattern, i, map[int]string{}, map[int]bool{}, 1); pos >= 0 {
-			return true, nil
-		}
-	}
-	return false, nil
-}
-
-func cloneCaps(c map[int]string, h map[int]bool) (map[int]string, map[int]bool) {
-	nc := make(map[int]string, len(c))
-	for k, v := range c {
-		nc[k] = v
-	}
-	nh := make(map[int]bool, len(h))
-	for k, v := range h {
-		nh[k] = v
-	}
-	return nc, nh
-}
-
-func matchHere(text, pattern string, textPos int, caps map[int]string, have map[int]bool, nextGroup int) (int, map[int]string, map[int]bool, int) {
-	patPos := 0
-	for patPos < len(pattern) {
-		// parentheses: alternation or capturing group
-		if pattern[patPos] == '(' {
-			end := findClosingParenAt(pattern, patPos)
-			if end < 0 {
-				return -1, caps, have, nextGroup
-			}
-			inside := pattern[patPos+1 : end]
-			
-			// Check for quantifiers after the group
-			var rest string
-			quantifier := ""
-			repeatCount := 0
-			minCount := 0
-			maxCount := 0
-			if end+1 < len(pattern) {
-				ch := pattern[end+1]
-				if ch == '+' || ch == '?' || ch == '*' {
-					quantifier = string(ch)
-					rest = pattern[end+2:]
-				} else if ch == '{' {
-					// Parse {n}, {n,}, or {n,m}
-					closeBrace := end + 2
-					for closeBrace < len(pattern) && pattern[closeBrace] != '}' {
-						closeBrace++
-					}
-					if closeBrace < len(pattern) {
-						content := pattern[end+2 : closeBrace]
-						// Check for comma
-						commaPos := -1
-						for i, c := range content {
-							if c == ',' {
-								commaPos = i
-								break
-							}
-						}
-						
-						if commaPos >= 0 {
-							// Has comma: {n,} or {n,m}
-							numStr1 := content[:commaPos]
-							numStr2 := content[commaPos+1:]
-							
-							// Parse first number
-							n := 0
-							valid := true
-							for _, c := range numStr1 {
-								if c >= '0' && c <= '9' {
-									n = n*10 + int(c-'0')
-								} else {
-									valid = false
-									break
-								}
-							}
-							
-							if !valid {
-								rest = pattern[end+1:]
-							} else if len(numStr2) == 0 {
-								// {n,} format - minimum repetition
-								quantifier = "{n,}"
-								minCount = n
-								rest = pattern[closeBrace+1:]
-							} else {
-								// {n,m} format - range repetition
-								m := 0
-								for _, c := range numStr2 {
-									if c >= '0' && c <= '9' {
-										m = m*10 + int(c-'0')
-									} else {
-										valid = false
-										break
-									}
-								}
-								if valid {
-									quantifier = "{n,m}"
-									minCount = n
-									maxCount = m
-									rest = pattern[closeBrace+1:]
-								} else {
-									rest = pattern[end+1:]
-								}
-							}
-						} else {
-							// {n} format - exact repetition
-							n := 0
-							valid := true
-							for _, c := range content {
-								if c >= '0' && c <= '9' {
-									n = n*10 + int(c-'0')
-								} else {
-									valid = false
-									break
-								}
-							}
-							if valid {
-								quantifier = "{n}"
-								repeatCount = n
-								rest = pattern[closeBrace+1:]
-							} else {
-								rest = pattern[end+1:]
-							}
-						}
-					} else {
-						rest = pattern[end+1:]
-					}
-				} else {
-					rest = pattern[end+1:]
-				}
-			} else {
-				rest = pattern[end+1:]
-			}
-			
-			alts := splitAlternation(inside)
-			// capturing group (numbered)
-			groupNo := nextGroup
-			
-			// Handle quantifiers on groups
-			if quantifier == "*" {
-				// zero or more times
-				// Try matching greedily first
-				saved := textPos
-				matches := 0
-				currentPos := textPos
-				var lastCaps map[int]string
-				var lastHave map[int]bool
-				var lastNextGroup int
-				
-				for {
-					matched := false
-					if len(alts) > 1 {
-						// Try each alternation
-						for _, alt := range alts {
-							nc, nh := cloneCaps(caps, have)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, nextGroup+1); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								lastCaps[groupNo] = text[saved:res] // capture the whole match
-								lastHave[groupNo] = true
-								currentPos = res
-								matches++
-								matched = true
-								break
-							}
-						}
-					} else {
-						// Simple group
-						nc, nh := cloneCaps(caps, have)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, nextGroup+1); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							lastCaps[groupNo] = text[saved:res]
-							lastHave[groupNo] = true
-							currentPos = res
-							matches++
-							matched = true
-						}
-					}
-					if !matched {
-						break
-					}
-				}
-				
-				// Backtrack from greedy match
-				if matches == 0 {
-					// Zero matches is ok for *
-					return matchHere(text, rest, saved, caps, have, nextGroup+1)
-				}
-				
-				// Try from currentPos down to saved
-				for tryPos := currentPos; tryPos >= saved; tryPos-- {
-					// Build capture state for this position
-					tc, th := cloneCaps(lastCaps, lastHave)
-					if tryPos > saved {
-						tc[groupNo] = text[saved:tryPos]
-						th[groupNo] = true
-					}
-					if restRes, icFinal, ihFinal, ngFinal := matchHere(text, rest, tryPos, tc, th, lastNextGroup); restRes >= 0 {
-						return restRes, icFinal, ihFinal, ngFinal
-					}
-				}
-				return -1, caps, have, nextGroup
-			}
-			
-			// Handle {n} quantifier on groups
-			if quantifier == "{n}" {
-				// Match exactly repeatCount times
-				currentPos := textPos
-				lastCaps := caps
-				lastHave := have
-				lastNextGroup := nextGroup + 1
-				
-				for i := 0; i < repeatCount; i++ {
-					matched := false
-					if len(alts) > 1 {
-						// Try each alternation
-						for _, alt := range alts {
-							nc, nh := cloneCaps(lastCaps, lastHave)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, lastNextGroup); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								currentPos = res
-								matched = true
-								break
-							}
-						}
-					} else {
-						// Simple group
-						nc, nh := cloneCaps(lastCaps, lastHave)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, lastNextGroup); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							currentPos = res
-							matched = true
-						}
-					}
-					if !matched {
-						return -1, caps, have, nextGroup
-					}
-				}
-				
-				// Successfully matched exactly repeatCount times
-				lastCaps[groupNo] = text[textPos:currentPos]
-				lastHave[groupNo] = true
-				return matchHere(text, rest, currentPos, lastCaps, lastHave, lastNextGroup)
-			}
-			
-			// Handle {n,} quantifier on groups (minimum repetition)
-			if quantifier == "{n,}" {
-				// First, match at least minCount times
-				lastCaps, lastHave := cloneCaps(caps, have)
-				lastNextGroup := nextGroup
-				currentPos := textPos
-				
-				// Match minimum required times
-				for i := 0; i < minCount; i++ {
-					matched := false
-					if len(alts) > 1 {
-						for _, alt := range alts {
-							nc, nh := cloneCaps(lastCaps, lastHave)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								currentPos = res
-								matched = true
-								break
-							}
-						}
-					} else {
-						nc, nh := cloneCaps(lastCaps, lastHave)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							currentPos = res
-							matched = true
-						}
-					}
-					if !matched {
-						// Couldn't match minimum required times
-						return -1, caps, have, nextGroup
-					}
-				}
-				
-				// Now match greedily as many more times as possible, with backtracking
-				positions := []int{currentPos}
-				capStates := []map[int]string{lastCaps}
-				haveStates := []map[int]bool{lastHave}
-				groupStates := []int{lastNextGroup}
-				
-				for currentPos < len(text) {
-					matched := false
-					if len(alts) > 1 {
-						for _, alt := range alts {
-							nc, nh := cloneCaps(lastCaps, lastHave)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								currentPos = res
-								positions = append(positions, currentPos)
-								capStates = append(capStates, lastCaps)
-								haveStates = append(haveStates, lastHave)
-								groupStates = append(groupStates, lastNextGroup)
-								matched = true
-								break
-							}
-						}
-					} else {
-						nc, nh := cloneCaps(lastCaps, lastHave)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							currentPos = res
-							positions = append(positions, currentPos)
-							capStates = append(capStates, lastCaps)
-							haveStates = append(haveStates, lastHave)
-							groupStates = append(groupStates, lastNextGroup)
-							matched = true
-						}
-					}
-					if !matched {
-						break
-					}
-				}
-				
-				// Backtrack from greedy to minimum
-				for i := len(positions) - 1; i >= 0; i-- {
-					endPos := positions[i]
-					stateCaps := capStates[i]
-					stateHave := haveStates[i]
-					stateGroup := groupStates[i]
-					
-					nc, nh := cloneCaps(stateCaps, stateHave)
-					nc[groupNo] = text[textPos:endPos]
-					nh[groupNo] = true
-					
-					if res, ic, ih, ng := matchHere(text, rest, endPos, nc, nh, stateGroup); res >= 0 {
-						return res, ic, ih, ng
-					}
-				}
-				return -1, caps, have, nextGroup
-			}
-			
-			// Handle {n,m} quantifier on groups (range repetition)
-			if quantifier == "{n,m}" {
-				// Match between minCount and maxCount times
-				lastCaps, lastHave := cloneCaps(caps, have)
-				lastNextGroup := nextGroup
-				currentPos := textPos
-				
-				// First, match minimum required times
-				for i := 0; i < minCount; i++ {
-					matched := false
-					if len(alts) > 1 {
-						for _, alt := range alts {
-							nc, nh := cloneCaps(lastCaps, lastHave)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								currentPos = res
-								matched = true
-								break
-							}
-						}
-					} else {
-						nc, nh := cloneCaps(lastCaps, lastHave)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							currentPos = res
-							matched = true
-						}
-					}
-					if !matched {
-						// Couldn't match minimum required times
-						return -1, caps, have, nextGroup
-					}
-				}
-				
-				// Now match greedily up to maxCount, with backtracking
-				positions := []int{currentPos}
-				capStates := []map[int]string{lastCaps}
-				haveStates := []map[int]bool{lastHave}
-				groupStates := []int{lastNextGroup}
-				
-				matchCount := minCount
-				for matchCount < maxCount && currentPos < len(text) {
-					matched := false
-					if len(alts) > 1 {
-						for _, alt := range alts {
-							nc, nh := cloneCaps(lastCaps, lastHave)
-							if res, ic, ih, ng := matchHere(text, alt, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-								lastCaps = ic
-								lastHave = ih
-								lastNextGroup = ng
-								currentPos = res
-								positions = append(positions, currentPos)
-								capStates = append(capStates, lastCaps)
-								haveStates = append(haveStates, lastHave)
-								groupStates = append(groupStates, lastNextGroup)
-								matchCount++
-								matched = true
-								break
-							}
-						}
-					} else {
-						nc, nh := cloneCaps(lastCaps, lastHave)
-						if res, ic, ih, ng := matchHere(text, inside, currentPos, nc, nh, lastNextGroup+1); res >= 0 {
-							lastCaps = ic
-							lastHave = ih
-							lastNextGroup = ng
-							currentPos = res
-							positions = append(positions, currentPos)
-							capStates = append(capStates, lastCaps)
-							haveStates = append(haveStates, lastHave)
-							groupStates = append(groupStates, lastNextGroup)
-							matchCount++
-							matched = true
-						}
-					}
-					if !matched {
-						break
-					}
-				}
-				
-				// Backtrack from greedy to minimum
-				for i := len(positions) - 1; i >= 0; i-- {
-					endPos := positions[i]
-					stateCaps := capStates[i]
-					stateHave := haveStates[i]
-					stateGroup := groupStates[i]
-					
-					nc, nh := cloneCaps(stateCaps, stateHave)
-					nc[groupNo] = text[textPos:endPos]
-					nh[groupNo] = true
-					
-					if res, ic, ih, ng := matchHere(text, rest, endPos, nc, nh, stateGroup); res >= 0 {
-						return res, ic, ih, ng
-					}
-				}
-				return -1, caps, have, nextGroup
-			}
-			
-			if len(alts) > 1 {
-				for _, alt := range alts {
-					nc, nh := cloneCaps(caps, have)
-					// inner groups start numbering from nextGroup+1
-					if res, ic, ih, ng := matchHere(text, alt, textPos, nc, nh, nextGroup+1); res >= 0 {
-						captured := text[textPos:res]
-						ic[groupNo] = captured
-						ih[groupNo] = true
-						return matchHere(text, rest, res, ic, ih, ng)
-					}
-				}
-				return -1, caps, have, nextGroup
-			}
-			// no alternation inside, simple capturing
-			// To handle quantifiers that need to backtrack across group boundaries,
-			// we try all possible group lengths and check if both inside and rest match
-			nc, nh := cloneCaps(caps, have)
-			if firstRes, ic, ih, ng := matchHere(text, inside, textPos, nc, nh, nextGroup+1); firstRes >= 0 {
-				// Try the natural match first
-				ic[groupNo] = text[textPos:firstRes]
-				ih[groupNo] = true
-				if restRes, icFinal, ihFinal, ngFinal := matchHere(text, rest, firstRes, ic, ih, ng); restRes >= 0 {
-					return restRes, icFinal, ihFinal, ngFinal
-				}
-				// Natural match failed, try shorter captures if pattern allows
-				// Only try alternatives if there's a quantifier that might match shorter
-				for tryEnd := firstRes - 1; tryEnd > textPos; tryEnd-- {
-					// Try this length: check if inside would accept it AND rest matches
-					// Build capture state for this attempt
-					ic2 := make(map[int]string, len(ic))
-					for k, v := range ic {
-						ic2[k] = v
-					}
-					ih2 := make(map[int]bool, len(ih))
-					for k, v := range ih {
-						ih2[k] = v
-					}
-					ic2[groupNo] = text[textPos:tryEnd]
-					ih2[groupNo] = true
-					// Try matching rest from tryEnd
-					if restRes2, icFinal2, ihFinal2, ngFinal2 := matchHere(text, rest, tryEnd, ic2, ih2, ng); restRes2 >= 0 {
-						// rest matched! Accept this shorter capture
-						return restRes2, icFinal2, ihFinal2, ngFinal2
-					}
-				}
-			}
-			return -1, caps, have, nextGroup
-		}
-
-		// determine element and possible quantifier
-		elemLen := 0
-		elem := ""
-		if pattern[patPos] == '\\' && patPos+1 < len(pattern) {
-			elemLen = 2
-			elem = pattern[patPos : patPos+2]
-		} else if pattern[patPos] == '[' {
-			end := patPos + 1
-			for end < len(pattern) && pattern[end] != ']' {
-				end++
-			}
-			if end >= len(pattern) {
-				return -1, caps, have, nextGroup
-			}
-			elemLen = end - patPos + 1
-			elem = pattern[patPos : end+1]
-		} else if pattern[patPos] == '.' {
-			elemLen = 1
-			elem = "."
-		} else {
-			elemLen = 1
-			elem = pattern[patPos : patPos+1]
-		}
-
-		// '+' quantifier (one or more)
-		if patPos+elemLen < len(pattern) && pattern[patPos+elemLen] == '+' {
-			rest := pattern[patPos+elemLen+1:]
-			saved := textPos
-			// consume greedily
-			count := 0
-			for textPos < len(text) && matchElement(text[textPos], elem) {
-				textPos++
-				count++
-			}
-			if count == 0 {
-				return -1, caps, have, nextGroup
-			}
-			for tp := textPos; tp >= saved+1; tp-- {
-				nc, nh := cloneCaps(caps, have)
-				if res, ncc, nhh, ng := matchHere(text, rest, tp, nc, nh, nextGroup); res >= 0 {
-					return res, ncc, nhh, ng
-				}
-			}
-			return -1, caps, have, nextGroup
-		}
-		// '*' quantifier (zero or more)
-		if patPos+elemLen < len(pattern) && pattern[patPos+elemLen] == '*' {
-			rest := pattern[patPos+elemLen+1:]
-			saved := textPos
-			// consume greedily
-			for textPos < len(text) && matchElement(text[textPos], elem) {
-				textPos++
-			}
-			// backtrack from greedy match down to zero
-			for tp := textPos; tp >= saved; tp-- {
-				nc, nh := cloneCaps(caps, have)
-				if res, ncc, nhh, ng := matchHere(text, rest, tp, nc, nh, nextGroup); res >= 0 {
-					return res, ncc, nhh, ng
-				}
-			}
-			return -1, caps, have, nextGroup
-		}
-		// '?' quantifier
-		if patPos+elemLen < len(pattern) && pattern[patPos+elemLen] == '?' {
-			rest := pattern[patPos+elemLen+1:]
-			// try with element
-			if textPos < len(text) && matchElement(text[textPos], elem) {
-				nc, nh := cloneCaps(caps, have)
-				if res, ncc, nhh, ng := matchHere(text, rest, textPos+1, nc, nh, nextGroup); res >= 0 {
-					return res, ncc, nhh, ng
-				}
-			}
-			// try without element
-			nc, nh := cloneCaps(caps, have)
-			return matchHere(text, rest, textPos, nc, nh, nextGroup)
-		}
-		// '{n}', '{n,}', or '{n,m}' quantifier
-		if patPos+elemLen < len(pattern) && pattern[patPos+elemLen] == '{' {
-			// Find closing }
-			closeBrace := patPos + elemLen + 1
-			for closeBrace < len(pattern) && pattern[closeBrace] != '}' {
-				closeBrace++
-			}
-			if closeBrace < len(pattern) {
-				content := pattern[patPos+elemLen+1 : closeBrace]
-				rest := pattern[closeBrace+1:]
-				
-				// Check for comma
-				commaPos := -1
-				for i, c := range content {
-					if c == ',' {
-						commaPos = i
-						break
-					}
-				}
-				
-				if commaPos >= 0 {
-					// Has comma: {n,} or {n,m}
-					numStr1 := content[:commaPos]
-					numStr2 := content[commaPos+1:]
-					
-					// Parse first number
-					n := 0
-					for _, ch := range numStr1 {
-						if ch >= '0' && ch <= '9' {
-							n = n*10 + int(ch-'0')
-						} else {
-							// Invalid format, treat as literal
-							goto notQuantifier
-						}
-					}
-					
-					if len(numStr2) == 0 {
-						// {n,} format - minimum repetition
-						// Match at least n times
-						for i := 0; i < n; i++ {
-							if textPos >= len(text) || !matchElement(text[textPos], elem) {
-								return -1, caps, have, nextGroup
-							}
-							textPos++
-						}
-						
-						// Now match greedily as many more as possible with backtracking
-						positions := []int{textPos}
-						for textPos < len(text) && matchElement(text[textPos], elem) {
-							textPos++
-							positions = append(positions, textPos)
-						}
-						
-						// Backtrack from greedy to minimum
-						for i := len(positions) - 1; i >= 0; i-- {
-							if res, ic, ih, ng := matchHere(text, rest, positions[i], caps, have, nextGroup); res >= 0 {
-								return res, ic, ih, ng
-							}
-						}
-						return -1, caps, have, nextGroup
-					} else {
-						// {n,m} format - range repetition
-						m := 0
-						for _, ch := range numStr2 {
-							if ch >= '0' && ch <= '9' {
-								m = m*10 + int(ch-'0')
-							} else {
-								// Invalid format, treat as literal
-								goto notQuantifier
-							}
-						}
-						
-						// Match at least n times
-						for i := 0; i < n; i++ {
-							if textPos >= len(text) || !matchElement(text[textPos], elem) {
-								return -1, caps, have, nextGroup
-							}
-							textPos++
-						}
-						
-						// Now match greedily up to m times with backtracking
-						positions := []int{textPos}
-						matchCount := n
-						for matchCount < m && textPos < len(text) && matchElement(text[textPos], elem) {
-							textPos++
-							positions = append(positions, textPos)
-							matchCount++
-						}
-						
-						// Backtrack from greedy to minimum
-						for i := len(positions) - 1; i >= 0; i-- {
-							if res, ic, ih, ng := matchHere(text, rest, positions[i], caps, have, nextGroup); res >= 0 {
-								return res, ic, ih, ng
-							}
-						}
-						return -1, caps, have, nextGroup
-					}
-				} else {
-					// {n} format - exact repetition
-					n := 0
-					for _, ch := range content {
-						if ch >= '0' && ch <= '9' {
-							n = n*10 + int(ch-'0')
-						} else {
-							// Invalid format, treat as literal
-							goto notQuantifier
-						}
-					}
-					// Match exactly n times
-					for i := 0; i < n; i++ {
-						if textPos >= len(text) || !matchElement(text[textPos], elem) {
-							return -1, caps, have, nextGroup
-						}
-						textPos++
-					}
-					// Matched exactly n times, continue with rest
-					return matchHere(text, rest, textPos, caps, have, nextGroup)
-				}
-			}
-		}
-	notQuantifier:
-
-		// consume single element
-		if elem == "\\d" {
-			if textPos >= len(text) || text[textPos] < '0' || text[textPos] > '9' {
-				return -1, caps, have, nextGroup
-			}
-			textPos++
-			patPos += elemLen
-			continue
-		}
-		if elem == "\\w" {
-			if textPos >= len(text) {
-				return -1, caps, have, nextGroup
-			}
-			b := text[textPos]
-			if !((b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_') {
-				return -1, caps, have, nextGroup
-			}
-			textPos++
-			patPos += elemLen
-			continue
-		}
-		if elemLen == 2 && elem[0] == '\\' && elem[1] >= '1' && elem[1] <= '9' {
-			gi := int(elem[1]-'0')
-			if !have[gi] {
-				return -1, caps, have, nextGroup
-			}
-			capv := caps[gi]
-			l := len(capv)
-			if textPos+l > len(text) || text[textPos:textPos+l] != capv {
-				return -1, caps, have, nextGroup
-			}
-			textPos += l
-			patPos += elemLen
-			continue
-		}
-		if len(elem) > 0 && elem[0] == '[' {
-			group := elem[1 : len(elem)-1]
-			if textPos >= len(text) || !matchCharGroup(text[textPos], group) {
-				return -1, caps, have, nextGroup
-			}
-			textPos++
-			patPos += elemLen
-			continue
-		}
-		if elem == "." {
-			if textPos >= len(text) {
-				return -1, caps, have, nextGroup
-			}
-			textPos++
-			patPos += elemLen
-			continue
-		}
-		// literal
-		if textPos >= len(text) || text[textPos] != elem[0] {
-			return -1, caps, have, nextGroup
-		}
-		textPos++
-		patPos += elemLen
-	}
-	return textPos, caps, have, nextGroup
-}
-
-func matchElement(char byte, elem string) bool {
-	if elem == "." {
-		return true
-	}
-	if elem == "\\d" {
-		return char >= '0' && char <= '9'
-	}
-	if elem == "\\w" {
-		return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') || char == '_'
-	}
-	if len(elem) > 0 && elem[0] == '[' && elem[len(elem)-1] == ']' {
-		return matchCharGroup(char, elem[1:len(elem)-1])
-	}
-	if len(elem) == 1 {
-		return char == elem[0]
-	}
-	return false
-}
-
-func matchCharGroup(char byte, group string) bool {
-	if len(group) > 0 && group[0] == '^' {
-		negGroup := group[1:]
-		for i := 0; i < len(negGroup); i++ {
-			if char == negGroup[i] {
-				return false
-			}
-		}
-		return true
-	}
-	
-	for i := 0; i < len(group); i++ {
-		if char == group[i] {
-			return true
-		}
-	}
-	return false
-}
-
-func findClosingParenAt(s string, start int) int {
-	if start >= len(s) || s[start] != '(' {
-		return -1
-	}
-	depth := 0
-	for i := start; i < len(s); i++ {
-		if s[i] == '(' {
-			depth++
-		} else if s[i] == ')' {
-			depth--
-			if depth == 0 {
-				return i
-			}
-		}
+	if binary {
+		fmt.Println("Binary file (standard input) matches")
 	}
-	return -1
 }
-
-func splitAlternation(s string) []string {
-	var result []string
-	var current string
-	depth := 0
-	
-	for i := 0; i < len(s); i++ {
-		if s[i] == '(' {
-			depth++
-			current += string(s[i])
-		} else if s[i] == ')' {
-			depth--
-			current += string(s[i])
-		} else if s[i] == '|' && depth == 0 {
-			result = append(result, current)
-			current = ""
-		} else {
-			current += string(s[i])
-		}
-	}
-	
-	if current != "" {
-		result = append(result, current)
-	}
-	
-	return result
-}
-
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = "usage: mygrep [options] -E <pattern> [filename|directory...]"
+
+// defaultMaxSteps keeps a pathological pattern from hanging on one line
+// while leaving ordinary searches far below the limit.
+const defaultMaxSteps = 10_000_000
+
+type options struct {
+	pattern       string
+	hasPattern    bool
+	paths         []string
+	recursive     bool
+	dereference   bool
+	devices       string
+	oneFileSystem bool
+	jobs          int
+	unordered     bool
+	noIgnore      bool
+	hidden        bool
+	// searchArchives searches the members of tar and zip archives
+	searchArchives bool
+
+	include    []string
+	exclude    []string
+	excludeDir []string
+	types      []string
+	notTypes   []string
+	typeAdd    []string
+	typeList   bool
+
+	binaryFiles string
+	decompress  bool
+	encoding    string
+	crlf        bool
+	cr          bool
+	noMessages  bool
+	lineNumbers bool
+	color       string
+	before      int
+	after       int
+	json        bool
+	multiLine   bool
+	onlyMatch   bool
+	replace     string
+	hasReplace  bool
+	inPlace     bool
+	dryRun      bool
+
+	maxSteps int
+	timeout  time.Duration
+	memoize  bool
+}
+
+// flagSpec describes one command-line option. Options that take a value
+// accept it as "--long=value", "--long value", "-xvalue" or "-x value".
+type flagSpec struct {
+	short byte
+	long  string
+	arg   bool
+	set   func(o *options, v string) error
+}
+
+var flagSpecs = []flagSpec{
+	{short: 'E', arg: true, set: func(o *options, v string) error {
+		o.pattern, o.hasPattern = v, true
+		return nil
+	}},
+	{short: 'r', long: "recursive", set: func(o *options, _ string) error {
+		o.recursive = true
+		return nil
+	}},
+	{long: "no-ignore", set: func(o *options, _ string) error {
+		o.noIgnore = true
+		return nil
+	}},
+	{long: "hidden", set: func(o *options, _ string) error {
+		o.hidden = true
+		return nil
+	}},
+	{long: "include", arg: true, set: func(o *options, v string) error {
+		o.include = append(o.include, v)
+		return nil
+	}},
+	{long: "exclude", arg: true, set: func(o *options, v string) error {
+		o.exclude = append(o.exclude, v)
+		return nil
+	}},
+	{long: "exclude-dir", arg: true, set: func(o *options, v string) error {
+		o.excludeDir = append(o.excludeDir, v)
+		return nil
+	}},
+	{short: 't', long: "type", arg: true, set: func(o *options, v string) error {
+		o.types = append(o.types, v)
+		return nil
+	}},
+	{short: 'T', long: "type-not", arg: true, set: func(o *options, v string) error {
+		o.notTypes = append(o.notTypes, v)
+		return nil
+	}},
+	{long: "type-add", arg: true, set: func(o *options, v string) error {
+		o.typeAdd = append(o.typeAdd, v)
+		return nil
+	}},
+	{long: "type-list", set: func(o *options, _ string) error {
+		o.typeList = true
+		return nil
+	}},
+	{long: "binary-files", arg: true, set: func(o *options, v string) error {
+		switch v {
+		case binaryBinary, binaryText, binaryWithoutMatch:
+			o.binaryFiles = v
+			return nil
+		}
+		return fmt.Errorf("invalid --binary-files %q, want binary, text or without-match", v)
+	}},
+	{short: 'a', long: "text", set: func(o *options, _ string) error {
+		o.binaryFiles = binaryText
+		return nil
+	}},
+	{short: 'z', long: "search-zip", set: func(o *options, _ string) error {
+		o.decompress = true
+		return nil
+	}},
+	{long: "search-archives", set: func(o *options, _ string) error {
+		o.searchArchives = true
+		return nil
+	}},
+	{long: "crlf", set: func(o *options, _ string) error {
+		o.crlf = true
+		return nil
+	}},
+	{long: "cr", set: func(o *options, _ string) error {
+		o.crlf, o.cr = true, true
+		return nil
+	}},
+	{long: "encoding", arg: true, set: func(o *options, v string) error {
+		enc, err := parseEncoding(v)
+		o.encoding = enc
+		return err
+	}},
+	{short: 'I', set: func(o *options, _ string) error {
+		o.binaryFiles = binaryWithoutMatch
+		return nil
+	}},
+	{short: 's', long: "no-messages", set: func(o *options, _ string) error {
+		o.noMessages = true
+		return nil
+	}},
+	{short: 'n', long: "line-number", set: func(o *options, _ string) error {
+		o.lineNumbers = true
+		return nil
+	}},
+	{long: "color", arg: true, set: func(o *options, v string) error {
+		switch v {
+		case colorAuto, colorAlways, colorNever:
+			o.color = v
+			return nil
+		}
+		return fmt.Errorf("invalid --color %q, want auto, always or never", v)
+	}},
+	{short: 'A', long: "after-context", arg: true, set: func(o *options, v string) error {
+		return parseCount(&o.after, v)
+	}},
+	{short: 'B', long: "before-context", arg: true, set: func(o *options, v string) error {
+		return parseCount(&o.before, v)
+	}},
+	{short: 'C', long: "context", arg: true, set: func(o *options, v string) error {
+		if err := parseCount(&o.after, v); err != nil {
+			return err
+		}
+		o.before = o.after
+		return nil
+	}},
+	{short: 'U', long: "multiline", set: func(o *options, _ string) error {
+		o.multiLine = true
+		return nil
+	}},
+	{short: 'o', long: "only-matching", set: func(o *options, _ string) error {
+		o.onlyMatch = true
+		return nil
+	}},
+	{long: "replace", arg: true, set: func(o *options, v string) error {
+		o.replace, o.hasReplace = v, true
+		return nil
+	}},
+	{long: "in-place", set: func(o *options, _ string) error {
+		o.inPlace = true
+		return nil
+	}},
+	{long: "dry-run", set: func(o *options, _ string) error {
+		o.dryRun = true
+		return nil
+	}},
+	{long: "json", set: func(o *options, _ string) error {
+		o.json = true
+		return nil
+	}},
+	{short: 'j', long: "threads", arg: true, set: func(o *options, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid thread count %q", v)
+		}
+		o.jobs = n
+		return nil
+	}},
+	{long: "unordered", set: func(o *options, _ string) error {
+		o.unordered = true
+		return nil
+	}},
+	{short: 'R', long: "dereference-recursive", set: func(o *options, _ string) error {
+		o.recursive, o.dereference = true, true
+		return nil
+	}},
+	{short: 'D', long: "devices", arg: true, set: func(o *options, v string) error {
+		if v != devicesRead && v != devicesSkip {
+			return fmt.Errorf("invalid -D %q, want read or skip", v)
+		}
+		o.devices = v
+		return nil
+	}},
+	{long: "one-file-system", set: func(o *options, _ string) error {
+		o.oneFileSystem = true
+		return nil
+	}},
+	{long: "max-steps", arg: true, set: func(o *options, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid --max-steps %q", v)
+		}
+		o.maxSteps = n
+		return nil
+	}},
+	{long: "timeout", arg: true, set: func(o *options, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid --timeout %q", v)
+		}
+		o.timeout = d
+		return nil
+	}},
+	{long: "memoize", set: func(o *options, _ string) error {
+		o.memoize = true
+		return nil
+	}},
+}
+
+// searchesStdin reports whether stdin, when there are no operands, must be
+// searched as "-" is rather than only checked for a match: the options ask
+// for output, or change how the input is read.
+func (o *options) searchesStdin() bool {
+	return o.json || o.hasReplace || o.onlyMatch || o.lineNumbers || o.color == colorAlways ||
+		o.before > 0 || o.after > 0 || o.multiLine || o.decompress || o.crlf || o.encoding != encodingAuto
+}
+
+// parseCount parses a non-negative count such as a number of context lines.
+func parseCount(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid count %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+func lookupShort(c byte) *flagSpec {
+	for i := range flagSpecs {
+		if flagSpecs[i].short == c {
+			return &flagSpecs[i]
+		}
+	}
+	return nil
+}
+
+func lookupLong(name string) *flagSpec {
+	for i := range flagSpecs {
+		if flagSpecs[i].long == name {
+			return &flagSpecs[i]
+		}
+	}
+	return nil
+}
+
+// parseArgs parses the command line (without the program name). Options
+// may appear anywhere; "--" ends them.
+func parseArgs(args []string) (*options, error) {
+	o := &options{
+		maxSteps:    defaultMaxSteps,
+		jobs:        runtime.GOMAXPROCS(0),
+		binaryFiles: binaryBinary,
+		encoding:    encodingAuto,
+		color:       colorAuto,
+	}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			o.paths = append(o.paths, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(a, "--"):
+			name, val, hasVal := strings.Cut(a[2:], "=")
+			f := lookupLong(name)
+			if f == nil {
+				return nil, fmt.Errorf("unknown option --%s", name)
+			}
+			if f.arg && !hasVal {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("option --%s requires an argument", name)
+				}
+				i++
+				val = args[i]
+			} else if !f.arg && hasVal {
+				return nil, fmt.Errorf("option --%s takes no argument", name)
+			}
+			if err := f.set(o, val); err != nil {
+				return nil, err
+			}
+		case len(a) > 1 && a[0] == '-':
+			// a bundle of short options such as -rE, the last of which
+			// may take a value
+			for j := 1; j < len(a); j++ {
+				f := lookupShort(a[j])
+				if f == nil {
+					return nil, fmt.Errorf("unknown option -%c", a[j])
+				}
+				val := ""
+				if f.arg {
+					if j+1 < len(a) {
+						val = a[j+1:]
+					} else if i+1 < len(args) {
+						i++
+						val = args[i]
+					} else {
+						return nil, fmt.Errorf("option -%c requires an argument", a[j])
+					}
+					j = len(a)
+				}
+				if err := f.set(o, val); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			o.paths = append(o.paths, a)
+		}
+	}
+	if !o.hasPattern && !o.typeList {
+		return nil, errors.New("no pattern given with -E")
+	}
+	if (o.inPlace || o.dryRun) && !o.hasReplace {
+		return nil, errors.New("--in-place and --dry-run need --replace")
+	}
+	if (o.inPlace || o.dryRun) && (o.decompress || o.multiLine || o.encoding != encodingAuto) {
+		// files are rewritten line by line, in the bytes they hold
+		return nil, errors.New("--in-place and --dry-run can't be combined with -z, -U or --encoding")
+	}
+	return o, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// The default budget bounds the backtracking from each offset, so a long
+// line doesn't exhaust it with a pattern that never backtracks.
+func TestDefaultMaxStepsLongLine(t *testing.T) {
+	opts, err := parseArgs([]string{"-E", "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := regex.CompileOptions(opts.pattern, regex.Options{MaxSteps: opts.maxSteps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("x", 2*opts.maxSteps) + "needle"
+	if ok, err := re.TryMatchString(line); !ok || err != nil {
+		t.Errorf("TryMatchString = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestSearchesStdin(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-E", "x"}, false},
+		{[]string{"-s", "-E", "x"}, false},
+		{[]string{"--color=never", "-E", "x"}, false},
+		{[]string{"--json", "-E", "x"}, true},
+		{[]string{"--replace", "y", "-E", "x"}, true},
+		{[]string{"-o", "-E", "x"}, true},
+		{[]string{"-n", "-E", "x"}, true},
+		{[]string{"-C", "1", "-E", "x"}, true},
+		{[]string{"--color=always", "-E", "x"}, true},
+		{[]string{"--encoding=latin1", "-E", "x"}, true},
+		{[]string{"--cr", "-E", "x"}, true},
+		{[]string{"-z", "-E", "x"}, true},
+	} {
+		opts, err := parseArgs(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := opts.searchesStdin(); got != tt.want {
+			t.Errorf("%q: searchesStdin() = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
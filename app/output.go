@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Values of --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorScheme holds the SGR sequences of GREP_COLORS.
+type colorScheme struct {
+	match     string // ms: matched text in selected lines
+	context   string // mc: matched text in context lines
+	selected  string // sl: whole selected lines
+	cxLine    string // cx: whole context lines
+	filename  string // fn
+	lineNum   string // ln
+	byteOff   string // bn
+	separator string // se
+	// noEraseLine drops the \33[K that GNU grep appends so that
+	// background colors extend to the end of the line (ne)
+	noEraseLine bool
+}
+
+func defaultColors() *colorScheme {
+	return &colorScheme{
+		match:     "01;31",
+		context:   "01;31",
+		filename:  "35",
+		lineNum:   "32",
+		byteOff:   "32",
+		separator: "36",
+	}
+}
+
+// parseGrepColors overrides the defaults with a GREP_COLORS value such as
+// "ms=01;32:fn=34:ne". Unknown capabilities are ignored, as GNU grep does.
+func parseGrepColors(env string) *colorScheme {
+	c := defaultColors()
+	for _, capability := range strings.Split(env, ":") {
+		name, val, _ := strings.Cut(capability, "=")
+		switch name {
+		case "mt":
+			c.match, c.context = val, val
+		case "ms":
+			c.match = val
+		case "mc":
+			c.context = val
+		case "sl":
+			c.selected = val
+		case "cx":
+			c.cxLine = val
+		case "fn":
+			c.filename = val
+		case "ln":
+			c.lineNum = val
+		case "bn":
+			c.byteOff = val
+		case "se":
+			c.separator = val
+		case "ne":
+			c.noEraseLine = true
+		}
+	}
+	return c
+}
+
+// colorsFor returns the color scheme for --color=mode. auto colors only a
+// terminal that isn't "dumb"; without colors the scheme is empty.
+func colorsFor(mode string) *colorScheme {
+	switch mode {
+	case colorNever:
+		return &colorScheme{}
+	case colorAuto:
+		if !term.IsTerminal(int(os.Stdout.Fd())) || os.Getenv("TERM") == "dumb" {
+			return &colorScheme{}
+		}
+	}
+	return parseGrepColors(os.Getenv("GREP_COLORS"))
+}
+
+// enabled reports whether the scheme colors anything.
+func (c *colorScheme) enabled() bool {
+	return *c != colorScheme{}
+}
+
+// outputLine is a line to print, either selected or context, or with -U
+// the lines spanned by a match.
+type outputLine struct {
+	number int
+	offset int // of the start of the line in the file
+	text   string
+	eol    string // the terminator that followed text in the input, if any
+	// submatch offsets of each match, as from FindAllStringSubmatchIndex;
+	// only computed when the printer wantsMatches
+	matches [][]int
+	// the text each match is replaced by with --replace, or nil
+	replacements []string
+	context      bool
+}
+
+// printer formats search results, either as text lines of the form
+// [filename:][lineno:]line, colored by colors (which is never nil), or
+// as JSON Lines events with --json.
+type printer struct {
+	withFilename bool
+	lineNumbers  bool
+	colors       *colorScheme
+	json         bool
+}
+
+// wantsMatches reports whether line needs the offsets of the matches.
+func (p *printer) wantsMatches() bool {
+	return p.json || p.colors.enabled()
+}
+
+// begin starts the output of a file, before its first line.
+func (p *printer) begin(w *bytes.Buffer, filename string) {
+	if p.json {
+		writeJSON(w, "begin", jsonBegin{Path: jsonBytes(filename)})
+	}
+}
+
+// end finishes the output of a file. binaryOffset is where the file was
+// found to be binary, or -1.
+func (p *printer) end(w *bytes.Buffer, filename string, st *searchStats, binaryOffset int) {
+	if p.json {
+		e := jsonEnd{Path: jsonBytes(filename), Stats: newJSONStats(st)}
+		if binaryOffset >= 0 {
+			e.BinaryOffset = &binaryOffset
+		}
+		writeJSON(w, "end", e)
+	}
+}
+
+// binaryMatch reports a match in a binary file, whose lines aren't printed.
+func (p *printer) binaryMatch(w *bytes.Buffer, filename string) {
+	if p.json {
+		p.begin(w, filename)
+		return
+	}
+	fmt.Fprintf(w, "Binary file %s matches\n", filename)
+}
+
+// groupSeparator separates lines that aren't adjacent when printing
+// context.
+func (p *printer) groupSeparator(w *bytes.Buffer) {
+	if !p.json {
+		p.paint(w, p.colors.separator, "--")
+		w.WriteByte('\n')
+	}
+}
+
+// summary ends the output of the whole search.
+func (p *printer) summary(w io.Writer, st *searchStats, elapsed time.Duration) {
+	if p.json {
+		var b bytes.Buffer
+		writeJSON(&b, "summary", jsonSummary{ElapsedTotal: newJSONDuration(elapsed), Stats: newJSONStats(st)})
+		w.Write(b.Bytes())
+	}
+}
+
+// line writes a selected or context line. A multi-line text is written as
+// one output line per line, numbered from l.number.
+func (p *printer) line(w *bytes.Buffer, filename string, l outputLine) {
+	if p.json {
+		p.jsonLine(w, filename, l)
+		return
+	}
+	c := p.colors
+	sep, lineColor := ":", c.selected
+	if l.context {
+		sep, lineColor = "-", c.cxLine
+	}
+	number := l.number
+	prefix := func() {
+		if p.withFilename {
+			p.paint(w, c.filename, filename)
+			p.paint(w, c.separator, sep)
+		}
+		if p.lineNumbers {
+			p.paint(w, c.lineNum, strconv.Itoa(number))
+			p.paint(w, c.separator, sep)
+		}
+	}
+	// write paints text, starting a new output line at each newline
+	write := func(sgr, text string) {
+		for {
+			before, after, found := strings.Cut(text, "\n")
+			p.paint(w, sgr, before)
+			if !found {
+				return
+			}
+			w.WriteByte('\n')
+			number++
+			prefix()
+			text = after
+		}
+	}
+
+	prefix()
+	last := 0
+	for i, m := range l.matches {
+		// a multi-line match may take in the newline ending the text
+		start, end := min(m[0], len(l.text)), min(m[1], len(l.text))
+		if l.replacements != nil {
+			// even an empty match is replaced, so ^ can insert a prefix
+			write(lineColor, l.text[last:start])
+			write(c.match, l.replacements[i])
+			last = end
+			continue
+		}
+		if start < last || end <= start {
+			continue
+		}
+		write(lineColor, l.text[last:start])
+		write(c.match, l.text[start:end])
+		last = end
+	}
+	write(lineColor, l.text[last:])
+	if l.eol == "\r\n" {
+		// keep the line ends of a CRLF file; a lone CR would hide the line
+		w.WriteByte('\r')
+	}
+	w.WriteByte('\n')
+}
+
+// paint writes text wrapped in the SGR sequence sgr, or as is when sgr is
+// empty.
+func (p *printer) paint(w *bytes.Buffer, sgr, text string) {
+	if sgr == "" || text == "" {
+		w.WriteString(text)
+		return
+	}
+	erase := "\x1b[K"
+	if p.colors.noEraseLine {
+		erase = ""
+	}
+	w.WriteString("\x1b[" + sgr + "m" + erase)
+	w.WriteString(text)
+	w.WriteString("\x1b[m" + erase)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+
+	"github.com/codecrafters-io/grep-starter-go/internal/osutil"
+)
+
+// mmapThreshold is the size from which regular files are memory-mapped
+// instead of read, which spares large files a copy into the heap.
+const mmapThreshold = 16 << 20
+
+// readFile returns the content of filename, or of stdin for stdinName.
+// With decompress, compressed content is decompressed as it is read.
+// Large regular files are memory-mapped where the platform allows, and
+// pipes and special files are read; either way, release must be called
+// once neither the content nor anything sliced from it is in use.
+func readFile(filename string, decompress bool) (content []byte, release func(), err error) {
+	release = func() {}
+	f := os.Stdin
+	if filename != stdinName {
+		if f, err = os.Open(filename); err != nil {
+			return nil, release, err
+		}
+		defer f.Close()
+	}
+	if decompress {
+		content, err = readDecompressed(filename, f)
+		return content, release, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Mode().IsRegular() && info.Size() >= mmapThreshold && info.Size() <= math.MaxInt {
+		if b, err := osutil.Mmap(f, int(info.Size())); err == nil {
+			return b, func() { osutil.Munmap(b) }, nil
+		}
+		// fall back to reading
+	}
+	content, err = io.ReadAll(f)
+	return content, release, err
+}
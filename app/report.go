@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// errorReporter prints per-path errors as they happen, so that one bad file
+// or directory doesn't stop the search, and remembers the paths involved
+// for the closing summary. It is safe for concurrent use.
+type errorReporter struct {
+	w     io.Writer
+	quiet bool // -s: suppress the messages but still count the errors
+
+	mu      sync.Mutex
+	skipped []string
+}
+
+func (e *errorReporter) report(path string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.skipped = append(e.skipped, path)
+	if !e.quiet {
+		fmt.Fprintf(e.w, "error: %v\n", err)
+	}
+}
+
+// warn prints a message that doesn't count as an error.
+func (e *errorReporter) warn(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.quiet {
+		fmt.Fprintf(e.w, "warning: "+format+"\n", args...)
+	}
+}
+
+// failed reports whether any error was reported.
+func (e *errorReporter) failed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.skipped) > 0
+}
+
+// summarize lists the skipped paths once the search is done.
+func (e *errorReporter) summarize() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.quiet || len(e.skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(e.w, "%d path(s) skipped because of errors:\n", len(e.skipped))
+	for _, p := range e.skipped {
+		fmt.Fprintf(e.w, "  %s\n", p)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diffContext is the number of unchanged lines around each hunk of the
+// --dry-run diff.
+const diffContext = 3
+
+// rewriteFile applies the --replace template to every matching line of
+// filename and writes the result back in place, or with dryRun prints what
+// would change as a unified diff. Binary files are left alone unless
+// binaryFiles is text. A UTF-8 byte order mark is kept; UTF-16 text, which
+// would have to be encoded again, is an error.
+func (s *searcher) rewriteFile(filename string) *fileResult {
+	start := time.Now()
+	r := &fileResult{filename: filename}
+	if filename == stdinName {
+		r.filename = stdinLabel
+		r.err = errors.New("standard input can't be rewritten in place")
+		return r
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		r.err = fmt.Errorf("read file %s: %w", filename, err)
+		return r
+	}
+	r.stats.searches = 1
+	r.stats.bytesSearched = int64(len(content))
+	if bytes.HasPrefix(content, bomUTF16LE) || bytes.HasPrefix(content, bomUTF16BE) {
+		r.err = fmt.Errorf("rewrite file %s: UTF-16 text can't be rewritten", filename)
+		return r
+	}
+	bom := ""
+	if bytes.HasPrefix(content, bomUTF8) {
+		bom = string(bomUTF8)
+		content = content[len(bom):]
+	}
+	if s.binaryFiles != binaryText && isBinary(content) {
+		return r
+	}
+
+	texts, eols := splitLines(string(content), s.crlf, s.cr)
+	// each line keeps its terminator, so joining them gives the file back
+	lines := make([]string, len(texts))
+	newLines := make([]string, len(texts))
+	for i, text := range texts {
+		lines[i] = text + eols[i]
+	}
+	changed := false
+	for i, text := range texts {
+		line := lines[i]
+		newLines[i] = line
+		if line == "" {
+			// nothing follows the final newline
+			continue
+		}
+		ok, err := s.re.TryMatchString(text)
+		if err != nil {
+			fmt.Fprintf(&r.stderr, "warning: %s:%d: %v, line left unchanged\n", filename, i+1, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		matches := s.re.FindAllStringSubmatchIndex(text, -1)
+		var b []byte
+		last := 0
+		for _, m := range matches {
+			b = append(b, text[last:m[0]]...)
+			b = s.re.ExpandString(b, s.replace, text, m)
+			last = m[1]
+		}
+		b = append(b, text[last:]...)
+		b = append(b, eols[i]...)
+		r.matched = true
+		r.stats.matchedLines++
+		r.stats.matches += len(matches)
+		if string(b) != line {
+			newLines[i] = string(b)
+			changed = true
+		}
+	}
+
+	if r.matched {
+		r.stats.searchesWithMatch = 1
+	}
+	if changed {
+		if s.dryRun {
+			writeDiff(&r.stdout, filename, lines, newLines)
+		} else if err := writeFileAtomic(filename, []byte(bom+strings.Join(newLines, ""))); err != nil {
+			r.err = fmt.Errorf("write file %s: %w", filename, err)
+		}
+	}
+	r.stats.bytesPrinted = int64(r.stdout.Len())
+	r.stats.elapsed = time.Since(start)
+	return r
+}
+
+// writeRewriteSummary tells how many replacements were made, or with
+// dryRun would be made, in how many files.
+func writeRewriteSummary(w io.Writer, st *searchStats, dryRun bool) {
+	verb := "made"
+	if dryRun {
+		verb = "would be made"
+	}
+	fmt.Fprintf(w, "%d replacement(s) %s in %d of %d file(s)\n", st.matches, verb, st.searchesWithMatch, st.searches)
+}
+
+// writeFileAtomic replaces the content of the file name with data. It
+// writes a temporary file in the same directory and renames it over the
+// original, so that readers see either the old or the new content, never a
+// mix. The file keeps its permissions; a symbolic link is kept and its
+// target rewritten.
+func writeFileAtomic(name string, data []byte) (err error) {
+	if name, err = filepath.EvalSymlinks(name); err != nil {
+		return err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// writeDiff writes a unified diff from old to new, the lines of a file
+// each with its terminator. The rewrite maps lines one to one, so old and new
+// have the same length and a hunk covers the same lines in both.
+func writeDiff(w *bytes.Buffer, name string, old, new []string) {
+	n := len(old)
+	if old[n-1] == "" {
+		// what follows the final newline
+		n--
+	}
+	name = filepath.ToSlash(name)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", name, name)
+	for i := 0; i < n; {
+		if old[i] == new[i] {
+			i++
+			continue
+		}
+		// changes less than two contexts apart share a hunk
+		end := i + 1
+		for j := end; j < n && j < end+2*diffContext; j++ {
+			if old[j] != new[j] {
+				end = j + 1
+			}
+		}
+		first, stop := max(i-diffContext, 0), min(end+diffContext, n)
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", first+1, stop-first, first+1, stop-first)
+		for k := first; k < stop; {
+			if old[k] == new[k] {
+				writeDiffLine(w, ' ', old[k])
+				k++
+				continue
+			}
+			run := k
+			for run < stop && old[run] != new[run] {
+				run++
+			}
+			for _, l := range old[k:run] {
+				writeDiffLine(w, '-', l)
+			}
+			for _, l := range new[k:run] {
+				writeDiffLine(w, '+', l)
+			}
+			k = run
+		}
+		i = stop
+	}
+}
+
+func writeDiffLine(w *bytes.Buffer, prefix byte, line string) {
+	w.WriteByte(prefix)
+	w.WriteString(line)
+	if !strings.HasSuffix(line, "\n") && !strings.HasSuffix(line, "\r") {
+		w.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// rewrite runs --replace repl --in-place over a file holding content and
+// returns what the file then holds.
+func rewrite(t *testing.T, content, pattern, repl string) (string, *fileResult) {
+	t.Helper()
+	opts, err := parseArgs([]string{"--in-place", "--replace", repl, "-E", pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := newSearcher(opts, regex.MustCompile(opts.pattern), nil, false).rewriteFile(name)
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), r
+}
+
+func TestRewriteKeepsUTF8BOM(t *testing.T) {
+	got, r := rewrite(t, "\xef\xbb\xbffoo\nbar\n", "^foo", "baz")
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if want := "\xef\xbb\xbfbaz\nbar\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRewriteRejectsUTF16(t *testing.T) {
+	content := "\xff\xfef\x00o\x00o\x00\n\x00"
+	got, r := rewrite(t, content, "foo", "bar")
+	if r.err == nil {
+		t.Error("no error for UTF-16 text")
+	}
+	if got != content {
+		t.Errorf("file changed to %q", got)
+	}
+}
+
+func TestRewriteOptions(t *testing.T) {
+	for _, args := range [][]string{
+		{"--in-place", "--replace", "y", "-z", "-E", "x"},
+		{"--dry-run", "--replace", "y", "-U", "-E", "x"},
+		{"--in-place", "--replace", "y", "--encoding", "latin1", "-E", "x"},
+	} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q) succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// fileResult is the outcome of searching one file. Output is buffered so
+// that files searched in parallel can still be printed whole and in order.
+type fileResult struct {
+	filename string
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	matched  bool
+	err      error
+	stats    searchStats
+}
+
+// searchStats counts the work done by a search, for --json.
+type searchStats struct {
+	elapsed           time.Duration
+	searches          int
+	searchesWithMatch int
+	bytesSearched     int64
+	bytesPrinted      int64
+	matchedLines      int
+	matches           int
+}
+
+func (s *searchStats) add(o *searchStats) {
+	s.elapsed += o.elapsed
+	s.searches += o.searches
+	s.searchesWithMatch += o.searchesWithMatch
+	s.bytesSearched += o.bytesSearched
+	s.bytesPrinted += o.bytesPrinted
+	s.matchedLines += o.matchedLines
+	s.matches += o.matches
+}
+
+// stdinName is the operand that stands for standard input, and
+// stdinLabel the name it is printed under.
+const (
+	stdinName  = "-"
+	stdinLabel = "(standard input)"
+)
+
+// searcher holds what is needed to search one file.
+type searcher struct {
+	re          *regex.Regexp
+	binaryFiles string
+	// crlf also ends lines at "\r\n", and cr at a lone "\r" too
+	crlf, cr bool
+	// decompress searches the content of compressed files (-z)
+	decompress bool
+	// encoding is the --encoding input is decoded from
+	encoding string
+	// archives searches the members of archives, those that filter
+	// selects (--search-archives)
+	archives bool
+	filter   *fileFilter
+	// lines of context printed before and after each match
+	before, after int
+	// with hasReplace, matches in selected lines are printed as replace
+	// expanded by re.ExpandString
+	replace    string
+	hasReplace bool
+	// with dryRun, rewriteFile prints a diff instead of writing files
+	dryRun bool
+	// multiLine matches across lines (-U); onlyMatching prints each match
+	// instead of the lines it is on (-o)
+	multiLine    bool
+	onlyMatching bool
+	out          *printer
+}
+
+// newSearcher returns the searcher for the options given on the command
+// line. withFilename prefixes each printed line with its file name, as it
+// always does when archives are searched, to tell their members apart.
+func newSearcher(opts *options, re *regex.Regexp, filter *fileFilter, withFilename bool) *searcher {
+	before, after := opts.before, opts.after
+	if opts.onlyMatch && !opts.json {
+		// as in GNU grep, -o prints no context
+		before, after = 0, 0
+	}
+	return &searcher{
+		re:           re,
+		binaryFiles:  opts.binaryFiles,
+		decompress:   opts.decompress,
+		encoding:     opts.encoding,
+		crlf:         opts.crlf,
+		cr:           opts.cr,
+		archives:     opts.searchArchives,
+		filter:       filter,
+		before:       before,
+		after:        after,
+		replace:      opts.replace,
+		hasReplace:   opts.hasReplace,
+		dryRun:       opts.dryRun,
+		multiLine:    opts.multiLine,
+		onlyMatching: opts.onlyMatch,
+		out: &printer{
+			withFilename: withFilename || opts.searchArchives,
+			lineNumbers:  opts.lineNumbers,
+			colors:       colorsFor(opts.color),
+			json:         opts.json,
+		},
+	}
+}
+
+// searchFile matches re against each line of filename, or with multiLine
+// against its whole content, and prints the matching lines, with their
+// context, through out. Binary files are handled according to binaryFiles,
+// after decompression. With archives, the members of an archive are
+// searched instead.
+func (s *searcher) searchFile(filename string) *fileResult {
+	if s.archives && archiveKind(filename) != "" {
+		return s.searchArchive(filename)
+	}
+	start := time.Now()
+	content, release, err := readFile(filename, s.decompress)
+	defer release()
+	if filename == stdinName {
+		filename = stdinLabel
+	}
+	r := &fileResult{filename: filename}
+	if err != nil {
+		r.err = fmt.Errorf("read file %s: %w", filename, err)
+		return r
+	}
+	s.searchContent(r, content, start)
+	return r
+}
+
+// searchContent searches content, which was read from r.filename from
+// start on, and records the outcome in r. The content is decoded to UTF-8
+// first, so matching and output see UTF-8 text. Nothing in r refers to
+// content once searchContent returns.
+func (s *searcher) searchContent(r *fileResult, content []byte, start time.Time) {
+	filename := r.filename
+	r.stats.searches = 1
+	r.stats.bytesSearched = int64(len(content))
+	content = decodeText(content, s.encoding)
+	binary := s.binaryFiles != binaryText && isBinary(content)
+	if binary && s.binaryFiles == binaryWithoutMatch {
+		return
+	}
+
+	// content is never modified, and everything printed is copied out
+	// before searchFile releases it, so the text can share its memory,
+	// which may be a mapping of the file
+	f := newFileSearch(s, r, unsafe.String(unsafe.SliceData(content), len(content)))
+	if s.multiLine {
+		f.searchBuffer(binary)
+	} else {
+		f.searchLines(binary)
+	}
+	f.printAfter(f.n)
+
+	if r.matched {
+		r.stats.searchesWithMatch = 1
+	}
+	if binary && r.matched {
+		s.out.binaryMatch(&r.stdout, filename)
+	}
+	r.stats.bytesPrinted = int64(r.stdout.Len())
+	r.stats.elapsed = time.Since(start)
+	if f.begun {
+		s.out.end(&r.stdout, filename, &r.stats, -1)
+	} else if binary && r.matched {
+		s.out.end(&r.stdout, filename, &r.stats, binaryOffset(content))
+	}
+}
+
+// fileSearch is the state of searchFile for one file.
+type fileSearch struct {
+	*searcher
+	r       *fileResult
+	text    string
+	lines   []string
+	eols    []string // the terminator of each line
+	offsets []int    // of the start of each line in text
+	// n is the number of lines to search, without the empty one that
+	// follows a final newline
+	n int
+
+	begun       bool
+	lastPrinted int // the last line printed, or -1
+	afterEnd    int // the last line of after context to print, or -1
+}
+
+func newFileSearch(s *searcher, r *fileResult, text string) *fileSearch {
+	lines, eols := splitLines(text, s.crlf, s.cr)
+	n := len(lines)
+	if lines[n-1] == "" {
+		n--
+	}
+	offsets := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		offsets[i] = offsets[i-1] + len(lines[i-1]) + len(eols[i-1])
+	}
+	return &fileSearch{searcher: s, r: r, text: text, lines: lines, eols: eols, offsets: offsets, n: n, lastPrinted: -1, afterEnd: -1}
+}
+
+// splitLines splits text into lines and the terminators that end them:
+// "\n", with crlf "\r\n" too, and with cr a lone "\r" as well. The last
+// line has no terminator, and is empty if text ends with one.
+func splitLines(text string, crlf, cr bool) (lines, eols []string) {
+	seps := "\n"
+	if crlf || cr {
+		seps = "\r\n"
+	}
+	start := 0
+	for i := 0; ; i++ {
+		j := strings.IndexAny(text[i:], seps)
+		if j < 0 {
+			break
+		}
+		i += j
+		var eol string
+		switch {
+		case text[i] == '\n':
+			eol = "\n"
+		case i+1 < len(text) && text[i+1] == '\n':
+			eol = "\r\n"
+		case cr:
+			eol = "\r"
+		default:
+			// a lone CR is part of the line
+			continue
+		}
+		lines = append(lines, text[start:i])
+		eols = append(eols, eol)
+		i += len(eol) - 1
+		start = i + 1
+	}
+	return append(lines, text[start:]), append(eols, "")
+}
+
+// searchLines matches each line on its own. In a binary file it stops at
+// the first match, whose lines aren't printed.
+func (f *fileSearch) searchLines(binary bool) {
+	for i, line := range f.lines[:f.n] {
+		ok, err := f.re.TryMatchString(line)
+		if err != nil {
+			// a runaway match on one line shouldn't stop the search
+			fmt.Fprintf(&f.r.stderr, "warning: %s:%d: %v, line skipped\n", f.r.filename, i+1, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		f.r.matched = true
+		f.r.stats.matchedLines++
+		if binary {
+			// one notice instead of lines full of control bytes
+			return
+		}
+		var matches [][]int
+		if f.out.wantsMatches() || f.hasReplace || f.onlyMatching {
+			matches = f.re.FindAllStringSubmatchIndex(line, -1)
+			f.r.stats.matches += len(matches)
+		} else {
+			f.r.stats.matches++
+		}
+		f.printMatch(i, i, matches)
+	}
+}
+
+// searchBuffer matches the whole text at once, so that a match may span
+// lines. Matches on overlapping lines are printed together.
+func (f *fileSearch) searchBuffer(binary bool) {
+	all, stop, err := f.findAll()
+	if err != nil {
+		// the matches before it are still reported
+		fmt.Fprintf(&f.r.stderr, "warning: %s:%d: %v, rest of file skipped\n", f.r.filename, f.lineOf(stop)+1, err)
+	}
+	if f.n == 0 {
+		return
+	}
+	// an empty match after the final newline isn't on any line
+	textEnd := f.offsets[f.n-1] + len(f.lines[f.n-1])
+	for len(all) > 0 && all[len(all)-1][0] > textEnd {
+		all = all[:len(all)-1]
+	}
+	if len(all) == 0 {
+		return
+	}
+	f.r.matched = true
+	if binary {
+		f.r.stats.matchedLines++
+		return
+	}
+	f.r.stats.matches += len(all)
+	for k := 0; k < len(all); {
+		first, last := f.lineOf(all[k][0]), f.lastLineOf(all[k])
+		j := k + 1
+		for ; j < len(all) && f.lineOf(all[j][0]) <= last; j++ {
+			last = max(last, f.lastLineOf(all[j]))
+		}
+		// printed offsets are relative to the first line
+		base := f.offsets[first]
+		matches := make([][]int, j-k)
+		for x, m := range all[k:j] {
+			rel := make([]int, len(m))
+			for y, off := range m {
+				rel[y] = off
+				if off >= 0 {
+					rel[y] -= base
+				}
+			}
+			matches[x] = rel
+		}
+		f.r.stats.matchedLines += last - first + 1
+		f.printMatch(first, last, matches)
+		k = j
+	}
+}
+
+// multiLineWindow is how much text past the start of a search -U hands the
+// engine at a time. A match that may read beyond it widens the window, so
+// a huge file is only searched whole when a match needs it.
+var multiLineWindow = 1 << 20
+
+// findAll returns the matches in the text, found as
+// regex.FindAllStringSubmatchIndex would find them but a window at a time.
+// If a match exceeds the budget it returns those before it along with the
+// offset where the search stopped.
+func (f *fileSearch) findAll() (all [][]int, stop int, err error) {
+	size := multiLineWindow
+	pos, prevEnd := 0, -1
+	for pos <= len(f.text) {
+		// windows end at a line end, so never inside a rune
+		end := len(f.text)
+		if pos+size < end {
+			if i := strings.IndexByte(f.text[pos+size:], '\n'); i >= 0 {
+				end = pos + size + i + 1
+			}
+		}
+		m, partial, err := f.re.TryFindStringSubmatchIndexFrom(f.text[:end], pos)
+		if err != nil {
+			return all, pos, err
+		}
+		if partial >= 0 && end < len(f.text) {
+			// the text after the window could change the outcome from
+			// partial on: search again from there, or with a wider window
+			if partial > pos {
+				pos = partial
+			} else {
+				size *= 2
+			}
+			continue
+		}
+		size = multiLineWindow
+		if m == nil {
+			break
+		}
+		// as in package regex, an empty match right after the previous
+		// match is ignored
+		accept := true
+		if m[1] == pos {
+			accept = m[0] != prevEnd
+			_, n := utf8.DecodeRuneInString(f.text[pos:])
+			pos += max(n, 1)
+		} else {
+			pos = m[1]
+		}
+		prevEnd = m[1]
+		if accept {
+			all = append(all, m)
+		}
+	}
+	return all, pos, nil
+}
+
+// trimEOL drops the line terminator that a multi-line match may end with.
+func trimEOL(s string) string {
+	if t, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(t, "\r")
+	}
+	return s
+}
+
+// lineOf returns the line that holds the byte at offset.
+func (f *fileSearch) lineOf(offset int) int {
+	return sort.Search(len(f.offsets), func(i int) bool { return f.offsets[i] > offset }) - 1
+}
+
+// lastLineOf returns the last line that match, given as submatch offsets
+// into text, spans.
+func (f *fileSearch) lastLineOf(match []int) int {
+	if match[1] > match[0] {
+		return f.lineOf(match[1] - 1)
+	}
+	return f.lineOf(match[0])
+}
+
+// printMatch prints lines first to last, which hold matches, along with
+// the context due before them. The offsets in matches are relative to the
+// start of line first. With onlyMatching, only the matches are printed.
+func (f *fileSearch) printMatch(first, last int, matches [][]int) {
+	f.printAfter(first)
+	for i := max(f.lastPrinted+1, first-f.before); i < first; i++ {
+		f.printLines(i, i, nil, nil, true)
+	}
+	// a multi-line match may end past the newline of line last
+	text := f.text[f.offsets[first]:]
+	var replacements []string
+	if f.hasReplace {
+		replacements = make([]string, len(matches))
+		for k, m := range matches {
+			replacements[k] = string(f.re.ExpandString(nil, f.replace, text, m))
+		}
+	}
+	if f.onlyMatching && !f.out.json {
+		for k, m := range matches {
+			if m[1] == m[0] {
+				continue
+			}
+			offset := f.offsets[first] + m[0]
+			line := f.lineOf(offset)
+			f.begin(line)
+			l := outputLine{
+				number:  line + 1,
+				offset:  offset,
+				text:    trimEOL(text[m[0]:m[1]]),
+				matches: [][]int{{0, m[1] - m[0]}},
+			}
+			if replacements != nil {
+				l.replacements = replacements[k : k+1]
+			}
+			f.out.line(&f.r.stdout, f.r.filename, l)
+			f.lastPrinted = line
+		}
+		f.lastPrinted = last
+	} else {
+		f.printLines(first, last, matches, replacements, false)
+	}
+	f.afterEnd = last + f.after
+}
+
+// printAfter prints the after context due before line limit.
+func (f *fileSearch) printAfter(limit int) {
+	for i := f.lastPrinted + 1; i < limit && i <= f.afterEnd; i++ {
+		f.printLines(i, i, nil, nil, true)
+	}
+}
+
+// begin starts the output of the file, or of a new group of lines starting
+// at line i. As in GNU grep, groups are only told apart when context is
+// printed.
+func (f *fileSearch) begin(i int) {
+	if !f.begun {
+		f.out.begin(&f.r.stdout, f.r.filename)
+		f.begun = true
+	}
+	if (f.before > 0 || f.after > 0) && f.lastPrinted >= 0 && i > f.lastPrinted+1 {
+		f.out.groupSeparator(&f.r.stdout)
+	}
+}
+
+// printLines prints lines first to last as one outputLine.
+func (f *fileSearch) printLines(first, last int, matches [][]int, replacements []string, context bool) {
+	f.begin(first)
+	f.out.line(&f.r.stdout, f.r.filename, outputLine{
+		number:       first + 1,
+		offset:       f.offsets[first],
+		text:         f.text[f.offsets[first] : f.offsets[last]+len(f.lines[last])],
+		eol:          f.eols[last],
+		matches:      matches,
+		replacements: replacements,
+		context:      context,
+	})
+	f.lastPrinted = last
+}
+
+// searchFiles runs search over the files received on files, on up to jobs
+// goroutines, and passes each result to emit, one call at a time. Results
+// are emitted in the order files arrive unless unordered is set, in which
+// case each is emitted as soon as it is ready.
+func searchFiles(files <-chan string, jobs int, unordered bool, search func(string) *fileResult, emit func(*fileResult)) {
+	type task struct {
+		filename string
+		result   chan *fileResult
+	}
+	jobs = max(jobs, 1)
+	tasks := make(chan task)
+	// pending holds the result channels of dispatched files in input order;
+	// its capacity bounds how far workers may run ahead of the printer.
+	pending := make(chan chan *fileResult, 4*jobs)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				r := search(t.filename)
+				if unordered {
+					mu.Lock()
+					emit(r)
+					mu.Unlock()
+					continue
+				}
+				t.result <- r
+			}
+		}()
+	}
+
+	go func() {
+		for f := range files {
+			t := task{filename: f, result: make(chan *fileResult, 1)}
+			if !unordered {
+				pending <- t.result
+			}
+			tasks <- t
+		}
+		close(tasks)
+		close(pending)
+	}()
+
+	for ch := range pending {
+		emit(<-ch)
+	}
+	wg.Wait()
+}
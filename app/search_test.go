@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/grep-starter-go/regex"
+)
+
+// searchOutput searches content as a file would be under the command line
+// args and returns what is printed.
+func searchOutput(t *testing.T, content string, args ...string) string {
+	t.Helper()
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := regex.CompileOptions(opts.pattern, regex.Options{MultiLine: opts.multiLine, CRLF: opts.crlf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fileResult{filename: "f"}
+	newSearcher(opts, re, nil, false).searchContent(r, []byte(content), time.Now())
+	return r.stdout.String()
+}
+
+func TestGroupSeparator(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		content string
+		want    string
+	}{
+		// without context, lines that aren't adjacent aren't separated
+		{[]string{"-E", "foo"}, "foo\nbar\nfoo\n", "foo\nfoo\n"},
+		{[]string{"-o", "-E", "fo"}, "foo\nbar\nfoo\n", "fo\nfo\n"},
+		{[]string{"-n", "-E", "foo"}, "foo\nbar\nfoo\n", "1:foo\n3:foo\n"},
+		{[]string{"--replace", "X", "-E", "foo"}, "foo\nbar\nfoo\n", "X\nX\n"},
+		{[]string{"-U", "-E", "o\nb"}, "foo\nbar\nbaz\nfoo\nbar\n", "foo\nbar\nfoo\nbar\n"},
+
+		{[]string{"-A", "1", "-E", "foo"}, "foo\nbar\nbaz\nfoo\n", "foo\nbar\n--\nfoo\n"},
+		{[]string{"-C", "1", "-E", "foo"}, "foo\nbar\nbaz\nqux\nfoo\n", "foo\nbar\n--\nqux\nfoo\n"},
+		{[]string{"-B", "1", "-E", "foo"}, "foo\nbar\nfoo\n", "foo\nbar\nfoo\n"},
+	} {
+		if got := searchOutput(t, tt.content, tt.args...); got != tt.want {
+			t.Errorf("%q on %q: got %q, want %q", tt.args, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestMultiLineWindow(t *testing.T) {
+	content := "one foo\ntwo\nthree foo\nfour\n\nfive foo six\nseven"
+	for _, args := range [][]string{
+		{"-U", "-E", "o\nt"},
+		{"-U", "-E", "(?s)one.*six"},
+		{"-U", "-n", "-E", `^\w+$`},
+		{"-U", "-o", "-E", `x*`},
+		{"-U", "-o", "-E", `\bf\w+`},
+		{"-U", "--json", "-E", "o\n\nf"},
+	} {
+		want := searchOutput(t, content, args...)
+		multiLineWindow = 3
+		got := searchOutput(t, content, args...)
+		multiLineWindow = 1 << 20
+		if got != want {
+			t.Errorf("%q with a small window: got %q, want %q", args, got, want)
+		}
+	}
+}
+
+func TestMultiLineBudget(t *testing.T) {
+	opts, err := parseArgs([]string{"-U", "-E", `(a|a)*b\n`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := regex.CompileOptions(opts.pattern, regex.Options{MultiLine: true, MaxSteps: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fileResult{filename: "f"}
+	content := "b\n" + strings.Repeat("a", 30) + "\n"
+	newSearcher(opts, re, nil, false).searchContent(r, []byte(content), time.Now())
+	if got := r.stdout.String(); got != "b\n" {
+		t.Errorf("got %q, want the match before the budget ran out", got)
+	}
+	if got, want := r.stderr.String(), "warning: f:2: "+regex.ErrBudgetExceeded.Error()+", rest of file skipped\n"; got != want {
+		t.Errorf("got warning %q, want %q", got, want)
+	}
+}
+
+func TestSearchArchiveNamesMembers(t *testing.T) {
+	opts, err := parseArgs([]string{"--search-archives", "-E", "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := newFileFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "foo\nbar\n")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	// a single archive operand still names the member of each line
+	r := newSearcher(opts, regex.MustCompile(opts.pattern), filter, false).searchFile(name)
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if got, want := r.stdout.String(), name+"!a.txt:foo\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, tt := range []struct {
+		text        string
+		crlf, cr    bool
+		lines, eols []string
+	}{
+		{"a\nb\n", false, false, []string{"a", "b", ""}, []string{"\n", "\n", ""}},
+		// a final line with no terminator
+		{"a\nb", false, false, []string{"a", "b"}, []string{"\n", ""}},
+		{"", false, false, []string{""}, []string{""}},
+		// without --crlf, "\r" is part of the line
+		{"a\r\nb\r", false, false, []string{"a\r", "b\r"}, []string{"\n", ""}},
+		{"a\r\nb\r\n", true, false, []string{"a", "b", ""}, []string{"\r\n", "\r\n", ""}},
+		// a lone CR ends a line only with --cr
+		{"a\rb\r\nc", true, false, []string{"a\rb", "c"}, []string{"\r\n", ""}},
+		{"a\rb\r\nc\r", false, true, []string{"a", "b", "c", ""}, []string{"\r", "\r\n", "\r", ""}},
+		{"a\r\rb", false, true, []string{"a", "", "b"}, []string{"\r", "\r", ""}},
+		{"a\n\r\nb", true, false, []string{"a", "", "b"}, []string{"\n", "\r\n", ""}},
+	} {
+		lines, eols := splitLines(tt.text, tt.crlf, tt.cr)
+		if !slices.Equal(lines, tt.lines) || !slices.Equal(eols, tt.eols) {
+			t.Errorf("%q (crlf %v, cr %v): got %q, %q, want %q, %q", tt.text, tt.crlf, tt.cr, lines, eols, tt.lines, tt.eols)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// defaultTypes maps the names accepted by -t and -T to the file name globs
+// they select. --type-add extends it.
+var defaultTypes = map[string][]string{
+	"c":        {"*.c", "*.h"},
+	"cpp":      {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.hxx", "*.h"},
+	"csharp":   {"*.cs"},
+	"css":      {"*.css", "*.scss", "*.sass", "*.less"},
+	"docker":   {"Dockerfile", "*.dockerfile", "Dockerfile.*"},
+	"go":       {"*.go"},
+	"html":     {"*.html", "*.htm"},
+	"java":     {"*.java"},
+	"js":       {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":     {"*.json"},
+	"kotlin":   {"*.kt", "*.kts"},
+	"make":     {"Makefile", "makefile", "GNUmakefile", "*.mk"},
+	"markdown": {"*.md", "*.markdown"},
+	"md":       {"*.md", "*.markdown"},
+	"php":      {"*.php"},
+	"proto":    {"*.proto"},
+	"py":       {"*.py", "*.pyi"},
+	"ruby":     {"*.rb", "Gemfile", "Rakefile"},
+	"rust":     {"*.rs"},
+	"sh":       {"*.sh", "*.bash", "*.zsh"},
+	"sql":      {"*.sql"},
+	"swift":    {"*.swift"},
+	"toml":     {"*.toml"},
+	"ts":       {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"txt":      {"*.txt"},
+	"xml":      {"*.xml"},
+	"yaml":     {"*.yaml", "*.yml"},
+}
+
+// fileTypes returns the type registry: the defaults plus the definitions
+// given with --type-add, each of the form "name:glob[,glob...]".
+func fileTypes(adds []string) (map[string][]string, error) {
+	types := make(map[string][]string, len(defaultTypes))
+	for name, globs := range defaultTypes {
+		types[name] = globs
+	}
+	for _, def := range adds {
+		name, globs, ok := strings.Cut(def, ":")
+		if !ok || name == "" || globs == "" {
+			return nil, fmt.Errorf("invalid --type-add %q, want name:glob", def)
+		}
+		types[name] = append(slices.Clip(types[name]), strings.Split(globs, ",")...)
+	}
+	return types, nil
+}
+
+// printTypeList writes the registry in the form used by --type-list.
+func printTypeList(w io.Writer, types map[string][]string) {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(types[name], ", "))
+	}
+}
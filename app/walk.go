@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/codecrafters-io/grep-starter-go/internal/osutil"
+)
+
+// Values of -D.
+const (
+	devicesRead = "read"
+	devicesSkip = "skip"
+)
+
+// walkFiles sends the files to search to files as they are discovered, so
+// that matching starts before a large tree has been fully enumerated.
+// Without -r the paths themselves are sent. Paths named on the command line
+// are always searched; below them, hidden entries and entries excluded by
+// ignore files are skipped unless --hidden or --no-ignore say otherwise, as
+// are entries rejected by filter; with --search-archives, archives are sent
+// unless excluded and filter selects their members instead. Entries that
+// cannot be read are reported to errs and the walk goes on.
+//
+// Symbolic links named on the command line are followed; those found in
+// the walk are followed only with -R, which also detects directory loops.
+// Devices, FIFOs and sockets found in the walk are skipped unless -D read
+// is given, and -D skip skips them on the command line too.
+func walkFiles(paths []string, opts *options, filter *fileFilter, errs *errorReporter, files chan<- string) {
+	w := &walker{opts: opts, filter: filter, errs: errs, files: files}
+	for _, path := range paths {
+		w.walkRoot(path)
+	}
+}
+
+type walker struct {
+	opts   *options
+	filter *fileFilter
+	errs   *errorReporter
+	files  chan<- string
+
+	rootDev uint64
+	// the directories being walked from the root down, for loop detection
+	ancestors []osutil.FileID
+}
+
+func (w *walker) walkRoot(root string) {
+	if root == stdinName || !w.opts.recursive && w.opts.devices != devicesSkip {
+		w.files <- root
+		return
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		w.errs.report(root, err)
+		return
+	}
+	if !info.IsDir() || !w.opts.recursive {
+		if !isSpecial(info.Mode()) || w.opts.devices != devicesSkip {
+			w.files <- root
+		}
+		return
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		w.errs.report(root, err)
+		return
+	}
+	id, _ := osutil.Identity(info)
+	w.rootDev = id.Dev
+	var m *ignoreMatcher
+	if !w.opts.noIgnore {
+		m = rootIgnoreMatcher(abs)
+	}
+	w.ancestors = append(w.ancestors[:0], id)
+	w.walkDir(root, abs, "", m)
+}
+
+// walkDir walks the directory at path, whose absolute form is abs and
+// whose path relative to the root is rel. m holds the ignore rules in
+// effect in it.
+func (w *walker) walkDir(path, abs, rel string, m *ignoreMatcher) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// whatever could be listed is still walked
+		w.errs.report(path, err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !w.opts.hidden && isHidden(name) {
+			continue
+		}
+		childPath := filepath.Join(path, name)
+		childAbs := filepath.Join(abs, name)
+		childRel := filepath.ToSlash(filepath.Join(rel, name))
+
+		mode := e.Type()
+		var info fs.FileInfo
+		if mode&fs.ModeSymlink != 0 {
+			if !w.opts.dereference {
+				continue
+			}
+			if info, err = os.Stat(childPath); err != nil {
+				w.errs.report(childPath, err)
+				continue
+			}
+			mode = info.Mode().Type()
+		}
+		isDir := mode.IsDir()
+		if !w.opts.noIgnore && m.ignored(childAbs, isDir) {
+			continue
+		}
+
+		if !isDir {
+			if isSpecial(mode) && w.opts.devices != devicesRead {
+				continue
+			}
+			if w.opts.searchArchives && archiveKind(name) != "" {
+				if !w.filter.skipArchive(childRel) {
+					w.files <- childPath
+				}
+				continue
+			}
+			if !w.filter.skipFile(childRel) {
+				w.files <- childPath
+			}
+			continue
+		}
+
+		if w.filter.skipDir(childRel) {
+			continue
+		}
+		if info == nil {
+			if info, err = e.Info(); err != nil {
+				w.errs.report(childPath, err)
+				continue
+			}
+		}
+		id, ok := osutil.Identity(info)
+		if ok && w.opts.oneFileSystem && id.Dev != w.rootDev {
+			continue
+		}
+		if ok && w.onStack(id) {
+			w.errs.warn("%s: recursive directory loop", childPath)
+			continue
+		}
+		var cm *ignoreMatcher
+		if !w.opts.noIgnore {
+			cm = m.child(childAbs)
+		}
+		w.ancestors = append(w.ancestors, id)
+		w.walkDir(childPath, childAbs, childRel, cm)
+		w.ancestors = w.ancestors[:len(w.ancestors)-1]
+	}
+}
+
+func (w *walker) onStack(id osutil.FileID) bool {
+	for _, a := range w.ancestors {
+		if a == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isSpecial reports whether mode is a device, FIFO or socket.
+func isSpecial(mode fs.FileMode) bool {
+	return mode&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket) != 0
+}
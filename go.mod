@@ -1,3 +1,11 @@
 module github.com/codecrafters-io/grep-starter-go
 
 go 1.24.0
+
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/term v0.32.0
+)
+
+require golang.org/x/sys v0.33.0 // indirect
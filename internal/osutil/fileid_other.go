@@ -0,0 +1,11 @@
+//go:build !unix
+
+package osutil
+
+import "io/fs"
+
+// Identity is unavailable on this platform, which disables directory loop
+// detection and --one-file-system.
+func Identity(info fs.FileInfo) (FileID, bool) {
+	return FileID{}, false
+}
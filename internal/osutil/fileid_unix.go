@@ -0,0 +1,17 @@
+//go:build unix
+
+package osutil
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// Identity returns the device and inode of the file described by info.
+func Identity(info fs.FileInfo) (FileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}, false
+	}
+	return FileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package osutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// Mmap maps the first size bytes of f into memory, read-only. The mapping
+// stays valid after f is closed and must be released with Munmap.
+func Mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// Munmap releases a mapping made by Mmap.
+func Munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
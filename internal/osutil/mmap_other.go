@@ -0,0 +1,18 @@
+//go:build !linux
+
+package osutil
+
+import (
+	"errors"
+	"os"
+)
+
+// Mmap is unavailable on this platform; files are read instead.
+func Mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Munmap releases a mapping made by Mmap.
+func Munmap(b []byte) error {
+	return errors.ErrUnsupported
+}
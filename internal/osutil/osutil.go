@@ -0,0 +1,9 @@
+// Package osutil holds the platform-specific file system helpers used by
+// mygrep. It lives outside package main, whose files are compiled as an
+// explicit list that ignores build constraints.
+package osutil
+
+// FileID identifies a file by device and inode number.
+type FileID struct {
+	Dev, Ino uint64
+}
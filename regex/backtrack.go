@@ -0,0 +1,383 @@
+package regex
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// deadlineEvery is how many steps run between checks of the wall clock.
+const deadlineEvery = 1024
+
+// maxMemoBits bounds the visited bitmap of a memoizing match; longer inputs
+// fall back to plain backtracking.
+const maxMemoBits = 32 << 20
+
+// job is a choice point: the alternative branch of an opSplit together with
+// the length of the undo log at the time the split was taken.
+type job struct {
+	pc   int
+	pos  int
+	undo int
+}
+
+// undo records the value a slot held before a thread overwrote it, so that
+// backtracking can restore the capture state instead of copying it.
+type undo struct {
+	slot int
+	old  int
+}
+
+// machine runs a prog over one input with an explicit backtracking stack,
+// so every combination of alternatives and repetition counts is explored in
+// priority order until one reaches opMatch.
+type machine struct {
+	prog  *prog
+	input string
+	stack []job
+	// slots are the capture slots of the running thread and, after a match,
+	// of the match; undo holds what to restore when a pending job resumes
+	slots []int
+	undo  []undo
+	// longest keeps searching after a match; best holds the longest so far
+	longest bool
+	best    []int
+	found   bool
+
+	// steps counts the instructions of the current attempt, and ticks
+	// those of the whole call, for the clock checks
+	steps    int
+	ticks    int
+	maxSteps int
+	deadline time.Time
+	err      error
+
+	// visited has one bit per (pos, pc) when memoizing, pos counted from
+	// base; a state that was already explored cannot lead to a match the
+	// first visit missed. Only the bits of offsets lo to hi are set, so
+	// only those need clearing.
+	memoize bool
+	visited []uint64
+	base    int
+	lo, hi  int
+
+	// hitEnd is set when the current attempt looks at the end of the input,
+	// where more text could change its outcome; partial is the first
+	// offset whose attempt did, the end of the input if there was no
+	// match, or -1
+	hitEnd  bool
+	partial int
+}
+
+func newMachine(p *prog) *machine {
+	return &machine{prog: p, slots: make([]int, p.nslot), hi: -1}
+}
+
+// reset prepares m for a match call over input, reusing what it allocated
+// for earlier ones.
+func (m *machine) reset(opts *Options, input string) {
+	m.input = input
+	m.stack = m.stack[:0]
+	m.undo = m.undo[:0]
+	m.longest = opts.Longest
+	m.ticks, m.maxSteps = 0, opts.MaxSteps
+	m.deadline = time.Time{}
+	if opts.Timeout > 0 {
+		m.deadline = time.Now().Add(opts.Timeout)
+	}
+	m.err = nil
+	if m.hi >= m.lo {
+		ninst := len(m.prog.insts)
+		clear(m.visited[:cap(m.visited)][m.lo*ninst/64 : ((m.hi+1)*ninst+63)/64])
+	}
+	m.lo, m.hi = len(input)+1, -1
+	m.visited = m.visited[:0]
+	// loops whose body can match empty are always memoized when possible,
+	// since the visited set is what gives them the semantics of package
+	// regexp; see genPlus
+	m.memoize = (opts.Memoize || m.prog.emptyLoops) && !m.prog.backrefs
+	m.partial = -1
+}
+
+// memoFrom sizes the visited bitmap for attempts starting at start or
+// later, which never go back before it, so that a search late in a long
+// input can still be memoized.
+func (m *machine) memoFrom(start int) {
+	m.base = start
+	if !m.memoize {
+		return
+	}
+	if bits := len(m.prog.insts) * (len(m.input) - start + 1); bits <= maxMemoBits {
+		n := (bits + 63) / 64
+		if cap(m.visited) < n {
+			m.visited = make([]uint64, n)
+		}
+		m.visited = m.visited[:n]
+	}
+}
+
+// match reports whether the program matches starting at some offset in
+// [start, len(input)], leaving the capture slots of the leftmost match in
+// m.slots. If the budget runs out it returns false with m.err set.
+func (m *machine) match(start int) bool {
+	end := len(m.input)
+	if m.prog.anchored {
+		// only offset 0 can satisfy the leading ^
+		end = start
+	}
+	m.memoFrom(start)
+	for pos := start; pos <= end && m.err == nil; pos += m.width(pos) {
+		ok := m.matchAt(pos)
+		if m.hitEnd && m.partial < 0 {
+			m.partial = pos
+		}
+		if ok {
+			return true
+		}
+	}
+	if !m.prog.anchored && m.partial < 0 {
+		// offsets past the end of the input were never tried
+		m.partial = len(m.input)
+	}
+	return false
+}
+
+// width returns the length of the rune at pos, or 1 at the end of the
+// input. Matches start and, when empty, advance on rune boundaries.
+func (m *machine) width(pos int) int {
+	if pos < len(m.input) && m.input[pos] >= utf8.RuneSelf {
+		_, n := utf8.DecodeRuneInString(m.input[pos:])
+		return n
+	}
+	return 1
+}
+
+func (m *machine) matchAt(pos int) bool {
+	// each starting offset gets the whole budget, so that it bounds the
+	// backtracking from one offset rather than growing with the input
+	m.steps = 0
+	for i := range m.slots {
+		m.slots[i] = -1
+	}
+	m.undo = m.undo[:0]
+	m.found = false
+	m.hitEnd = false
+	m.stack = append(m.stack[:0], job{pc: 0, pos: pos})
+	for len(m.stack) > 0 {
+		j := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		m.rollback(j.undo)
+		if m.run(j.pc, j.pos) {
+			return true
+		}
+		if m.err != nil {
+			return false
+		}
+	}
+	if m.found {
+		copy(m.slots, m.best)
+	}
+	return m.found
+}
+
+// record keeps the current match if it is the longest found so far.
+func (m *machine) record() {
+	if m.found && m.slots[1] <= m.best[1] {
+		return
+	}
+	if m.best == nil {
+		m.best = make([]int, len(m.slots))
+	}
+	copy(m.best, m.slots)
+	m.found = true
+}
+
+// step charges one instruction against the budget.
+func (m *machine) step() bool {
+	m.steps++
+	m.ticks++
+	if m.maxSteps > 0 && m.steps > m.maxSteps {
+		m.err = ErrBudgetExceeded
+		return false
+	}
+	if !m.deadline.IsZero() && m.ticks%deadlineEvery == 0 && time.Now().After(m.deadline) {
+		m.err = ErrBudgetExceeded
+		return false
+	}
+	return true
+}
+
+// seen marks (pc, pos) as explored and reports whether it already was.
+func (m *machine) seen(pc, pos int) bool {
+	pos -= m.base
+	bit := pos*len(m.prog.insts) + pc
+	w, mask := bit/64, uint64(1)<<(bit%64)
+	if m.visited[w]&mask != 0 {
+		return true
+	}
+	m.visited[w] |= mask
+	m.lo, m.hi = min(m.lo, pos), max(m.hi, pos)
+	return false
+}
+
+// set stores pos in slot i, logging the old value if a pending job may
+// need it back.
+func (m *machine) set(i, pos int) {
+	if len(m.stack) > 0 {
+		m.undo = append(m.undo, undo{i, m.slots[i]})
+	}
+	m.slots[i] = pos
+}
+
+// rollback restores the slots to their state when the undo log had n
+// entries.
+func (m *machine) rollback(n int) {
+	for i := len(m.undo) - 1; i >= n; i-- {
+		m.slots[m.undo[i].slot] = m.undo[i].old
+	}
+	m.undo = m.undo[:n]
+}
+
+// run follows one thread until it fails or matches, pushing a job for the
+// other branch of every split it takes.
+func (m *machine) run(pc, pos int) bool {
+	insts, slots := m.prog.insts, m.slots
+	memo := len(m.visited) > 0
+	for {
+		if !m.step() {
+			return false
+		}
+		if memo && m.seen(pc, pos) {
+			return false
+		}
+		in := &insts[pc]
+		switch in.op {
+		case opByte:
+			if pos >= len(m.input) {
+				m.hitEnd = true
+				return false
+			}
+			if m.input[pos] != in.b {
+				return false
+			}
+			pos++
+			pc++
+		case opClass:
+			if pos >= len(m.input) {
+				m.hitEnd = true
+				return false
+			}
+			if c := m.input[pos]; c < utf8.RuneSelf {
+				if !in.class.has(rune(c)) {
+					return false
+				}
+				pos++
+			} else {
+				r, n := utf8.DecodeRuneInString(m.input[pos:])
+				if r == utf8.RuneError && !utf8.FullRuneInString(m.input[pos:]) {
+					// a rune cut short by the end of the input
+					m.hitEnd = true
+				}
+				if !in.class.has(r) {
+					return false
+				}
+				pos += n
+			}
+			pc++
+		case opAssert:
+			if !m.assert(assertion(in.arg), pos) {
+				return false
+			}
+			pc++
+		case opBackref:
+			s, e := slots[2*in.arg], slots[2*in.arg+1]
+			if s < 0 || e < s {
+				return false
+			}
+			n := e - s
+			if pos+n > len(m.input) {
+				m.hitEnd = true
+				return false
+			}
+			if text := m.input[pos : pos+n]; text != m.input[s:e] && !(in.fold && strings.EqualFold(text, m.input[s:e])) {
+				return false
+			}
+			pos += n
+			pc++
+		case opSave:
+			m.set(in.arg, pos)
+			pc++
+		case opCheck:
+			// when memoizing, the visited set already stops empty loops
+			if !memo {
+				if slots[in.arg] == pos {
+					return false
+				}
+				m.set(in.arg, pos)
+			}
+			pc++
+		case opSplit:
+			m.stack = append(m.stack, job{pc: in.alt, pos: pos, undo: len(m.undo)})
+			pc = in.arg
+		case opJmp:
+			pc = in.arg
+		case opMatch:
+			if m.longest {
+				// every other path must be tried for a longer match
+				m.record()
+				return false
+			}
+			return true
+		}
+	}
+}
+
+// assert reports whether assertion a holds at pos.
+func (m *machine) assert(a assertion, pos int) bool {
+	in := m.input
+	switch a {
+	case assertBegin:
+		return pos == 0
+	case assertBeginLine:
+		return pos == 0 || in[pos-1] == '\n'
+	case assertEnd:
+		return m.atEnd(pos, false)
+	case assertEndLine:
+		return m.atEnd(pos, true)
+	case assertEndText:
+		if pos == len(in) {
+			m.hitEnd = true
+			return true
+		}
+		return false
+	}
+	// \b and \B, between ASCII word characters as in package regexp
+	before := pos > 0 && isWordByte(in[pos-1])
+	if pos == len(in) {
+		m.hitEnd = true
+	}
+	after := pos < len(in) && isWordByte(in[pos])
+	return (before != after) == (a == assertWordBoundary)
+}
+
+// atEnd reports whether $ matches at pos: at the end of the input or, in
+// multi-line mode, before a newline. With CRLF it also matches before a
+// "\r" that ends the input or, in multi-line mode, a "\r\n", but not
+// between the two.
+func (m *machine) atEnd(pos int, multiLine bool) bool {
+	in, p := m.input, m.prog
+	switch {
+	case pos == len(in):
+		m.hitEnd = true
+		return true
+	case p.crlf && in[pos] == '\r':
+		if pos+1 == len(in) {
+			m.hitEnd = true
+			return true
+		}
+		return multiLine && in[pos+1] == '\n'
+	case multiLine && in[pos] == '\n':
+		return !p.crlf || pos == 0 || in[pos-1] != '\r'
+	}
+	return false
+}
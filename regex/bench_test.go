@@ -0,0 +1,93 @@
+package regex
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchText is a line the benchmark patterns match near its end.
+var benchText = strings.Repeat("the quick brown fox jumps over the lazy dog ", 4) + "user@example.com 2024-06-01"
+
+// allocFreePatterns match without allocating once their Regexp has pooled
+// a machine.
+var allocFreePatterns = []string{
+	`example`,
+	`\d\d\d\d-\d\d`,
+	`^the [a-z]+ brown`,
+	// backtracking restores captures from the undo log
+	`(\w+)@(\w+)\.com`,
+	`(quick|lazy) (brown|red) (fox|dog)`,
+	`[a-z]+ \d+-\d+-\d+`,
+}
+
+func TestMatchAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop machines")
+	}
+	for _, p := range allocFreePatterns {
+		re := MustCompile(p)
+		b := []byte(benchText)
+		if n := testing.AllocsPerRun(100, func() { re.MatchString(benchText) }); n != 0 {
+			t.Errorf("MatchString with %q: %v allocations, want 0", p, n)
+		}
+		if n := testing.AllocsPerRun(100, func() { re.Match(b) }); n != 0 {
+			t.Errorf("Match with %q: %v allocations, want 0", p, n)
+		}
+	}
+}
+
+func benchmarkMatch(b *testing.B, pattern string) {
+	re := MustCompile(pattern)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for b.Loop() {
+		if !re.MatchString(benchText) {
+			b.Fatalf("%q doesn't match", pattern)
+		}
+	}
+}
+
+func BenchmarkMatchLiteral(b *testing.B) {
+	benchmarkMatch(b, `example`)
+}
+
+func BenchmarkMatchClass(b *testing.B) {
+	benchmarkMatch(b, `\d\d\d\d-\d\d`)
+}
+
+func BenchmarkMatchAnchored(b *testing.B) {
+	benchmarkMatch(b, `^the [a-z]+ brown`)
+}
+
+func BenchmarkMatchAlternation(b *testing.B) {
+	benchmarkMatch(b, `(quick|lazy) (brown|red) (fox|dog)`)
+}
+
+func BenchmarkMatchRepetition(b *testing.B) {
+	benchmarkMatch(b, `(\w+)@(\w+)\.com`)
+}
+
+func BenchmarkMatchRepetitionClass(b *testing.B) {
+	benchmarkMatch(b, `[a-z]+ \d+-\d+-\d+`)
+}
+
+func BenchmarkFindAllSubmatch(b *testing.B) {
+	re := MustCompile(`(\w+) (\w+)`)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchText)))
+	for b.Loop() {
+		re.FindAllStringSubmatchIndex(benchText, -1)
+	}
+}
+
+func BenchmarkMatchBytes(b *testing.B) {
+	re := MustCompile(`example`)
+	text := []byte(benchText)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	for b.Loop() {
+		if !re.Match(text) {
+			b.Fatal("no match")
+		}
+	}
+}
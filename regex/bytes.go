@@ -0,0 +1,123 @@
+package regex
+
+import (
+	"io"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// The []byte methods match the slice in place: the engine never modifies
+// its input or keeps it past the call, so the bytes can be viewed as a
+// string without a copy.
+func bytesString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// Match reports whether b contains a match of the pattern. A match that
+// exceeds the budget is reported as no match; use TryMatch to tell the two
+// apart.
+func (re *Regexp) Match(b []byte) bool {
+	return re.MatchString(bytesString(b))
+}
+
+// TryMatch reports whether b contains a match of the pattern, or
+// ErrBudgetExceeded if that could not be decided within the budget.
+func (re *Regexp) TryMatch(b []byte) (bool, error) {
+	return re.TryMatchString(bytesString(b))
+}
+
+// Find returns the leftmost match of the pattern in b, as a slice of b, or
+// nil if there is none.
+func (re *Regexp) Find(b []byte) []byte {
+	loc := re.FindIndex(b)
+	if loc == nil {
+		return nil
+	}
+	return b[loc[0]:loc[1]:loc[1]]
+}
+
+// FindIndex returns the start and end offsets of the leftmost match of the
+// pattern in b, or nil if there is none.
+func (re *Regexp) FindIndex(b []byte) []int {
+	return re.FindStringIndex(bytesString(b))
+}
+
+// FindSubmatchIndex is FindStringSubmatchIndex for byte slices.
+func (re *Regexp) FindSubmatchIndex(b []byte) []int {
+	return re.FindStringSubmatchIndex(bytesString(b))
+}
+
+// FindSubmatch returns the leftmost match of the pattern in b and the text
+// of its capturing groups, as slices of b, with nil for a group that did
+// not participate. It returns nil if there is no match.
+func (re *Regexp) FindSubmatch(b []byte) [][]byte {
+	loc := re.FindSubmatchIndex(b)
+	if loc == nil {
+		return nil
+	}
+	return subslices(b, loc)
+}
+
+// subslices returns the slice of b bounded by each group in loc.
+func subslices(b []byte, loc []int) [][]byte {
+	out := make([][]byte, len(loc)/2)
+	for i := range out {
+		if s, e := loc[2*i], loc[2*i+1]; s >= 0 {
+			out[i] = b[s:e:e]
+		}
+	}
+	return out
+}
+
+// FindAll is FindAllString for byte slices; the matches are slices of b.
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	var out [][]byte
+	for _, loc := range re.FindAllIndex(b, n) {
+		out = append(out, b[loc[0]:loc[1]:loc[1]])
+	}
+	return out
+}
+
+// FindAllSubmatch is FindAllStringSubmatch for byte slices.
+func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
+	var out [][][]byte
+	for _, loc := range re.FindAllSubmatchIndex(b, n) {
+		out = append(out, subslices(b, loc))
+	}
+	return out
+}
+
+// FindAllIndex is FindAllStringIndex for byte slices.
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	return re.FindAllStringIndex(bytesString(b), n)
+}
+
+// FindAllSubmatchIndex is FindAllStringSubmatchIndex for byte slices.
+func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	return re.FindAllStringSubmatchIndex(bytesString(b), n)
+}
+
+// MatchReader reports whether the text read from r contains a match of the
+// pattern. Backtracking needs the whole text, so r is read to its end.
+func (re *Regexp) MatchReader(r io.RuneReader) bool {
+	return re.Match(readRunes(r))
+}
+
+// FindReaderIndex returns the byte offsets of the leftmost match in the
+// UTF-8 encoding of the text read from r, or nil if there is none. Like
+// MatchReader it reads r to its end.
+func (re *Regexp) FindReaderIndex(r io.RuneReader) []int {
+	return re.FindIndex(readRunes(r))
+}
+
+// readRunes returns the UTF-8 encoding of what r yields before an error.
+func readRunes(r io.RuneReader) []byte {
+	var b []byte
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return b
+		}
+		b = utf8.AppendRune(b, c)
+	}
+}
@@ -0,0 +1,185 @@
+package regex
+
+import (
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The case folding orbits of package unicode span these runes.
+const (
+	minFold = 0x0041
+	maxFold = 0x1e943
+)
+
+// class is a set of runes: a bitmap for ASCII, which most input is, and
+// sorted, disjoint ranges for the rest.
+type class struct {
+	ascii  [2]uint64
+	ranges []rune // lo, hi pairs, all >= utf8.RuneSelf
+}
+
+func (c *class) has(r rune) bool {
+	if r < utf8.RuneSelf {
+		return c.ascii[r>>6]&(1<<(r&63)) != 0
+	}
+	lo, hi := 0, len(c.ranges)/2
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		switch {
+		case r < c.ranges[2*m]:
+			hi = m
+		case r > c.ranges[2*m+1]:
+			lo = m + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// runeRanges is a class under construction: lo, hi pairs in any order,
+// possibly overlapping.
+type runeRanges []rune
+
+func (rs *runeRanges) add(lo, hi rune) {
+	*rs = append(*rs, lo, hi)
+}
+
+func (rs *runeRanges) addRanges(o runeRanges) {
+	*rs = append(*rs, o...)
+}
+
+func (rs *runeRanges) addTable(t *unicode.RangeTable) {
+	for _, r := range t.R16 {
+		rs.addStride(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range t.R32 {
+		rs.addStride(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+}
+
+func (rs *runeRanges) addStride(lo, hi, stride rune) {
+	if stride == 1 {
+		rs.add(lo, hi)
+		return
+	}
+	for r := lo; r <= hi; r += stride {
+		rs.add(r, r)
+	}
+}
+
+// addFolded adds every rune that is equal to one in rs under simple case
+// folding, such as 'K' and the Kelvin sign for 'k'.
+func (rs *runeRanges) addFolded() {
+	n := len(*rs)
+	for i := 0; i < n; i += 2 {
+		lo, hi := (*rs)[i], (*rs)[i+1]
+		if lo <= minFold && hi >= maxFold {
+			// every orbit is already in the range
+			continue
+		}
+		for r := max(lo, minFold); r <= min(hi, maxFold); r++ {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				rs.add(f, f)
+			}
+		}
+	}
+}
+
+// clean sorts the ranges and merges those that overlap or touch.
+func (rs runeRanges) clean() runeRanges {
+	pairs := make([][2]rune, 0, len(rs)/2)
+	for i := 0; i < len(rs); i += 2 {
+		pairs = append(pairs, [2]rune{rs[i], rs[i+1]})
+	}
+	slices.SortFunc(pairs, func(a, b [2]rune) int { return int(a[0] - b[0]) })
+	var out runeRanges
+	for _, p := range pairs {
+		if n := len(out); n > 0 && p[0] <= out[n-1]+1 {
+			out[n-1] = max(out[n-1], p[1])
+			continue
+		}
+		out = append(out, p[0], p[1])
+	}
+	return out
+}
+
+// negate returns the complement of the clean ranges rs.
+func (rs runeRanges) negate() runeRanges {
+	var out runeRanges
+	next := rune(0)
+	for i := 0; i < len(rs); i += 2 {
+		if rs[i] > next {
+			out = append(out, next, rs[i]-1)
+		}
+		next = rs[i+1] + 1
+	}
+	if next <= unicode.MaxRune {
+		out = append(out, next, unicode.MaxRune)
+	}
+	return out
+}
+
+// newClass builds the class of the clean ranges rs.
+func newClass(rs runeRanges) *class {
+	c := &class{}
+	for i := 0; i < len(rs); i += 2 {
+		lo, hi := rs[i], rs[i+1]
+		for r := lo; r <= hi && r < utf8.RuneSelf; r++ {
+			c.ascii[r>>6] |= 1 << (r & 63)
+		}
+		if hi >= utf8.RuneSelf {
+			c.ranges = append(c.ranges, max(lo, utf8.RuneSelf), hi)
+		}
+	}
+	return c
+}
+
+var (
+	digitRanges = runeRanges{'0', '9'}
+	spaceRanges = runeRanges{'\t', '\n', '\f', '\r', ' ', ' '}
+	wordRanges  = runeRanges{'0', '9', 'A', 'Z', '_', '_', 'a', 'z'}
+)
+
+// isWordByte reports whether b is an ASCII word character, as \w and \b
+// take them.
+func isWordByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '_'
+}
+
+// posixClasses are the [:name:] classes allowed in brackets.
+var posixClasses = map[string]runeRanges{
+	"alnum":  {'0', '9', 'A', 'Z', 'a', 'z'},
+	"alpha":  {'A', 'Z', 'a', 'z'},
+	"ascii":  {0, 0x7f},
+	"blank":  {'\t', '\t', ' ', ' '},
+	"cntrl":  {0, 0x1f, 0x7f, 0x7f},
+	"digit":  digitRanges,
+	"graph":  {'!', '~'},
+	"lower":  {'a', 'z'},
+	"print":  {' ', '~'},
+	"punct":  {'!', '/', ':', '@', '[', '`', '{', '~'},
+	"space":  {'\t', '\r', ' ', ' '},
+	"upper":  {'A', 'Z'},
+	"word":   wordRanges,
+	"xdigit": {'0', '9', 'A', 'F', 'a', 'f'},
+}
+
+// unicodeClass returns the ranges of a Unicode category or script, as
+// named by \p, or false if there is none by that name.
+func unicodeClass(name string) (runeRanges, bool) {
+	if name == "Any" {
+		return runeRanges{0, unicode.MaxRune}, true
+	}
+	t := unicode.Categories[name]
+	if t == nil {
+		t = unicode.Scripts[name]
+	}
+	if t == nil {
+		return nil, false
+	}
+	var rs runeRanges
+	rs.addTable(t)
+	return rs, true
+}
@@ -0,0 +1,172 @@
+package regex
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// The functions and methods here complete the API of package regexp, so
+// that a *Regexp can stand in for a *regexp.Regexp.
+
+// CompilePOSIX is like Compile but the Regexp prefers leftmost-longest
+// matches, as with Longest.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	return CompileOptions(expr, Options{Longest: true})
+}
+
+// MustCompilePOSIX is like CompilePOSIX but panics if the pattern cannot be
+// parsed.
+func MustCompilePOSIX(expr string) *Regexp {
+	re, err := CompilePOSIX(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// MatchString reports whether s contains a match of pattern.
+func MatchString(pattern string, s string) (bool, error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// Match reports whether b contains a match of pattern.
+func Match(pattern string, b []byte) (bool, error) {
+	return MatchString(pattern, bytesString(b))
+}
+
+// MatchReader reports whether the text read from r contains a match of
+// pattern.
+func MatchReader(pattern string, r io.RuneReader) (bool, error) {
+	return Match(pattern, readRunes(r))
+}
+
+// QuoteMeta returns s with every metacharacter escaped, as a pattern that
+// matches s literally.
+func QuoteMeta(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\.+*?()|[]{}^$`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Longest makes future searches prefer leftmost-longest matches. It must
+// not be called while the Regexp is in use.
+func (re *Regexp) Longest() {
+	re.opts.Longest = true
+}
+
+// Copy returns a new Regexp for the same pattern.
+//
+// Deprecated: a Regexp is safe for concurrent use, as in package regexp.
+func (re *Regexp) Copy() *Regexp {
+	return &Regexp{expr: re.expr, prog: re.prog, names: re.names, opts: re.opts}
+}
+
+// LiteralPrefix returns the literal text every match must begin with, and
+// whether that text is the whole of the pattern. Groups and a leading ^
+// are looked through, and of alternatives the text they all begin with is
+// taken, even where package regexp gives up, as on (a)|(a).
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	// insts[0] saves the start of the match
+	return re.prog.literalPrefix(1, 0)
+}
+
+// maxPrefixSplits bounds the splits literalPrefix looks through.
+const maxPrefixSplits = 8
+
+// literalPrefix returns the literal text that every path from pc begins
+// with, and whether each path then reaches opMatch. depth counts the
+// splits followed, up to maxPrefixSplits: each doubles the paths, and a
+// loop would be followed forever.
+func (p *prog) literalPrefix(pc, depth int) (string, bool) {
+	var b []byte
+	exact := true
+	for {
+		in := &p.insts[pc]
+		switch in.op {
+		case opByte:
+			b = append(b, in.b)
+			pc++
+		case opSave:
+			pc++
+		case opAssert:
+			if assertion(in.arg) != assertBegin {
+				return string(b), false
+			}
+			// matches may begin with the text even if not all text does
+			exact = false
+			pc++
+		case opJmp:
+			pc = in.arg
+		case opMatch:
+			return string(b), exact
+		case opSplit:
+			if depth == maxPrefixSplits {
+				return string(b), false
+			}
+			p1, _ := p.literalPrefix(in.arg, depth+1)
+			p2, _ := p.literalPrefix(in.alt, depth+1)
+			n := 0
+			for n < len(p1) && n < len(p2) && p1[n] == p2[n] {
+				n++
+			}
+			// a character the two begin differently is left out whole
+			for n > 0 && (n < len(p1) && !utf8.RuneStart(p1[n]) || n < len(p2) && !utf8.RuneStart(p2[n])) {
+				n--
+			}
+			return string(b) + p1[:n], false
+		default:
+			return string(b), false
+		}
+	}
+}
+
+// Expand is ExpandString for byte slices.
+func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
+	return re.ExpandString(dst, bytesString(template), bytesString(src), match)
+}
+
+// ReplaceAllLiteral is ReplaceAllLiteralString for byte slices.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	return []byte(re.ReplaceAllLiteralString(string(src), string(repl)))
+}
+
+// FindReaderSubmatchIndex is like FindReaderIndex but also returns the
+// offsets of the capturing groups, as FindStringSubmatchIndex does.
+func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
+	return re.FindSubmatchIndex(readRunes(r))
+}
+
+// MarshalText implements encoding.TextMarshaler: the text is the pattern.
+// Options, including Longest, are not kept.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	return []byte(re.expr), nil
+}
+
+// AppendText implements encoding.TextAppender.
+func (re *Regexp) AppendText(b []byte) ([]byte, error) {
+	return append(b, re.expr...), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by compiling the text
+// with Compile.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	n, err := Compile(string(text))
+	if err != nil {
+		return err
+	}
+	re.expr, re.prog, re.names, re.opts = n.expr, n.prog, n.names, n.opts
+	// machines are built for one program
+	re.machines = sync.Pool{}
+	return nil
+}
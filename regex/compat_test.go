@@ -0,0 +1,366 @@
+package regex
+
+import (
+	"bufio"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// conformanceTests are matched with FindAllStringSubmatchIndex against
+// package regexp, under each input.
+var conformanceTests = []struct {
+	pattern string
+	inputs  []string
+}{
+	// runes
+	{`^.$`, []string{"é", "a", "\n", "\xff"}},
+	{`x*`, []string{"éx", "xéx", "日本"}},
+	{``, []string{"日本語"}},
+	{`[^a]`, []string{"aé日"}},
+	{`é+`, []string{"éé", "\xc3\xc3"}},
+	{`[é-ö]+|\x{65e5}`, []string{"aéöø日"}},
+
+	// lazy quantifiers
+	{`a*?`, []string{"aaa"}},
+	{`a+?`, []string{"aaa"}},
+	{`a??b`, []string{"ab", "b"}},
+	{`<.+?>`, []string{"<a><b>"}},
+	{`(a{1,3}?)(a*)`, []string{"aaaa"}},
+	{`(a|ab)*?c`, []string{"abc"}},
+	{`(?U)a+`, []string{"aaa"}},
+	{`(?U)a+?`, []string{"aaa"}},
+
+	// intervals
+	{`a{,2}`, []string{"a{,2}", "aa"}},
+	{`a{2}`, []string{"aaaaa"}},
+	{`a{2,}?`, []string{"aaaaa"}},
+	{`a{x}`, []string{"a{x}"}},
+
+	// flags
+	{`(?i)abc`, []string{"ABC", "aBc", "abd"}},
+	{`(?i)k`, []string{"K", "K"}},
+	{`(?i)[a-c]+`, []string{"AbC"}},
+	{`a(?i)b|c`, []string{"aB", "C"}},
+	{`(?i:a)b`, []string{"Ab", "AB"}},
+	{`(?i)a(?-i)b`, []string{"Ab", "AB"}},
+	{`(?m)^\w+$`, []string{"one\ntwo\n"}},
+	{`(?s).+`, []string{"a\nb"}},
+	{`.+`, []string{"a\nb"}},
+
+	// assertions
+	{`\bfoo\b`, []string{"foo", "a foo.", "foobar", "éfooé"}},
+	{`\B..\B`, []string{"abcd"}},
+	{`\Aa|b\z`, []string{"ab", "ba"}},
+	{`$`, []string{"a\n"}},
+
+	// classes
+	{`\pL+`, []string{"héllo 日本 123"}},
+	{`\PL+`, []string{"héllo 日本 123"}},
+	{`\p{Greek}+`, []string{"abc αβγ"}},
+	{`\p{^Greek}+`, []string{"abc αβγ"}},
+	{`[[:alpha:]]+`, []string{"ab1cd"}},
+	{`[[:^alpha:][:digit:]]+`, []string{"ab1-cd"}},
+	{`[\d\s]+`, []string{"a1 2\tb"}},
+	{`\S+\W`, []string{"ab, cd"}},
+	{`[]a]+`, []string{"]a]"}},
+	{`[^]a]+`, []string{"]ab]"}},
+	{`(?i)[^k]`, []string{"KKkx"}},
+	{`(?i)\w`, []string{"Kſ"}},
+
+	// escapes
+	{`\x41\x{42}\x{1F600}`, []string{"AB😀"}},
+	{`\0\012\0101`, []string{"\x00\n\b1"}},
+	{`\Qa.b*\E+`, []string{"a.b**", "a.b"}},
+	{`\.\*\(\)\[\]\{\}\|\^\$\\\-\#\_`, []string{`.*()[]{}|^$\-#_`}},
+	{`[\-\]\\]+`, []string{`-]\`}},
+	{`\a\f\t\n\r\v`, []string{"\a\f\t\n\r\v"}},
+
+	// empty iterations
+	{`(a*)*`, []string{"b", "aab"}},
+	{`(a*?)*`, []string{"aab"}},
+	{`(\w*?\B)*`, []string{"1aa x"}},
+}
+
+func TestConformance(t *testing.T) {
+	for _, tt := range conformanceTests {
+		want := regexp.MustCompile(tt.pattern)
+		re, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		for _, s := range tt.inputs {
+			got, want := re.FindAllStringSubmatchIndex(s, -1), want.FindAllStringSubmatchIndex(s, -1)
+			if !slices.EqualFunc(got, want, slices.Equal) {
+				t.Errorf("%q on %q: got %v, want %v", tt.pattern, s, got, want)
+			}
+		}
+	}
+}
+
+// The atoms and quantifiers of random patterns, and the characters of the
+// inputs they are matched against.
+var (
+	randomAtoms = []string{
+		"a", "b", "é", ".", "[ab]", "[^a]", `\w`, `\W`, `\d`, `\s`, `\b`, `\B`,
+		"^", "$", `\A`, `\z`, `\pL`, `\PL`, `[[:alpha:]]`, `[[:^digit:]]`,
+		`\x{e9}`, `[é-ö]`, `\n`, "(?i:a)", "(?i:é)", "(?s:.)", "(?m:^)", "(?m:$)",
+	}
+	randomQuantifiers = []string{
+		"", "", "*", "+", "?", "*?", "+?", "??", "{2}", "{1,2}", "{0,2}?", "{2,}", "{,2}",
+	}
+	randomChars = []string{"a", "b", "A", "é", "É", "1", " ", "\n", "x", "\xff"}
+)
+
+func randomPattern(r *rand.Rand, depth int) string {
+	q := randomQuantifiers[r.Intn(len(randomQuantifiers))]
+	if depth == 0 || r.Intn(3) == 0 {
+		return randomAtoms[r.Intn(len(randomAtoms))] + q
+	}
+	switch r.Intn(4) {
+	case 0:
+		return randomPattern(r, depth-1) + randomPattern(r, depth-1)
+	case 1:
+		return randomPattern(r, depth-1) + "|" + randomPattern(r, depth-1)
+	case 2:
+		return "(" + randomPattern(r, depth-1) + ")" + q
+	}
+	return "(?:" + randomPattern(r, depth-1) + ")" + q
+}
+
+// TestRandomConformance matches random patterns against package regexp,
+// plain, memoized and leftmost-longest.
+func TestRandomConformance(t *testing.T) {
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		pattern := randomPattern(r, 1+r.Intn(4))
+		if r.Intn(5) == 0 {
+			pattern = []string{"(?i)", "(?U)", "(?s)", "(?m)", "(?i-s)"}[r.Intn(5)] + pattern
+		}
+		var inputs []string
+		for range 4 {
+			var b strings.Builder
+			for j := r.Intn(7); j > 0; j-- {
+				b.WriteString(randomChars[r.Intn(len(randomChars))])
+			}
+			inputs = append(inputs, b.String())
+		}
+		for _, opts := range []Options{{}, {Memoize: true}, {Longest: true}} {
+			want := regexp.MustCompile(pattern)
+			if opts.Longest {
+				want.Longest()
+			}
+			re, err := CompileOptions(pattern, opts)
+			if err != nil {
+				t.Fatalf("CompileOptions(%q, %+v): %v", pattern, opts, err)
+			}
+			for _, s := range inputs {
+				got, want := re.FindAllStringSubmatchIndex(s, -1), want.FindAllStringSubmatchIndex(s, -1)
+				if !slices.EqualFunc(got, want, slices.Equal) {
+					t.Fatalf("%q on %q (%+v): got %v, want %v", pattern, s, opts, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestConformanceErrors(t *testing.T) {
+	for _, pattern := range []string{
+		`\q`, `\é`, `\8`, `(a)\2`, `\`, `\x4`, `\x{}`, `\x{110000}`, `\pX`, `\p{Foo}`,
+		`(?x)`, `(?=a)`, `(?!a)`, `(?<=a)`, `(?<!a)`, `(?-)`, `(?i-)`, `(?--i)`, `(?i`, `(?P=n)`,
+		`[[:foo:]]`, `[z-a]`, `[\d-z`, `[\b]`, `[a`, `a)`, `(a`, `x{1001}`, `x{2,1}`,
+	} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+// TestDifferences pins down the patterns the package doc lists as parsing
+// differently from package regexp.
+func TestDifferences(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, input string
+		want           []int
+	}{
+		{`*a`, "*a", []int{0, 2}},
+		{`a**`, "aa", []int{0, 2}},
+		{`(a)\1`, "aa", []int{0, 2, 0, 1}},
+		{`(?i)(a)\1`, "aA", []int{0, 2, 0, 1}},
+		{"\xff+", "\xff\xff", []int{0, 2}},
+	} {
+		if _, err := regexp.Compile(tt.pattern); err == nil {
+			t.Errorf("regexp accepts %q", tt.pattern)
+		}
+		re, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := re.FindStringSubmatchIndex(tt.input); !slices.Equal(got, tt.want) {
+			t.Errorf("%q on %q: got %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestRE2Search runs the search tests that package regexp takes from RE2,
+// in testdata/re2-search.txt of the Go tree: each pattern, whole and
+// anchored at both ends, leftmost-first and leftmost-longest, on each
+// string. Patterns this package reads differently, as the package doc
+// lists, are skipped.
+func TestRE2Search(t *testing.T) {
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Skipf("go env GOROOT: %v", err)
+	}
+	file := filepath.Join(strings.TrimSpace(string(goroot)), "src", "regexp", "testdata", "re2-search.txt")
+	f, err := os.Open(file)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+
+	var (
+		strs      []string
+		input     []string
+		inStrings bool
+		pattern   string
+		res       [4]*Regexp
+		ncase     int
+	)
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		switch {
+		case line == "" || line[0] == '#' || 'A' <= line[0] && line[0] <= 'Z':
+			// a comment or the name of a test
+		case line == "strings":
+			strs = strs[:0]
+			inStrings = true
+		case line == "regexps":
+			inStrings = false
+		case line[0] == '"':
+			q, err := strconv.Unquote(line)
+			if err != nil {
+				t.Fatalf("%s:%d: %v", file, lineno, err)
+			}
+			if inStrings {
+				strs = append(strs, q)
+				continue
+			}
+			pattern, input = q, strs
+			res = [4]*Regexp{}
+			if _, err := regexp.Compile(q); err != nil || readsDifferently(q) {
+				continue
+			}
+			full := `\A(?:` + q + `)\z`
+			for i, opts := range []struct {
+				expr string
+				opts Options
+			}{{full, Options{}}, {q, Options{}}, {full, Options{Longest: true}}, {q, Options{Longest: true}}} {
+				if res[i], err = CompileOptions(opts.expr, opts.opts); err != nil {
+					t.Fatalf("%s:%d: CompileOptions(%q): %v", file, lineno, opts.expr, err)
+				}
+			}
+		case line[0] == '-' || '0' <= line[0] && line[0] <= '9':
+			if len(input) == 0 {
+				t.Fatalf("%s:%d: out of sync: no input left", file, lineno)
+			}
+			text := input[0]
+			input = input[1:]
+			if res[0] == nil {
+				continue
+			}
+			if !isSingleBytes(text) && strings.Contains(pattern, `\B`) {
+				// RE2 sees \B inside UTF-8 sequences; package regexp skips
+				// these too
+				continue
+			}
+			ncase++
+			for i, want := range strings.Split(line, ";") {
+				if got := res[i].FindStringSubmatchIndex(text); !slices.Equal(got, parseRE2Result(t, want)) {
+					t.Errorf("%s:%d: %q on %q: got %v, want %s", file, lineno, res[i], text, got, want)
+				}
+			}
+		default:
+			t.Fatalf("%s:%d: out of sync: %s", file, lineno, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d cases tested", ncase)
+}
+
+// readsDifferently reports whether package regexp accepts pattern but
+// reads it differently from this package: \1 to \9 are back-references
+// here, not octal codes.
+func readsDifferently(pattern string) bool {
+	for i := 0; i+1 < len(pattern); i++ {
+		if pattern[i] == '\\' {
+			if c := pattern[i+1]; '1' <= c && c <= '9' {
+				return true
+			}
+			i++
+		}
+	}
+	return false
+}
+
+func isSingleBytes(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRE2Result parses a result of re2-search.txt: "-" for no match, or
+// the space-separated start-end pairs of the match and its groups, "-" for
+// a group that did not participate.
+func parseRE2Result(t *testing.T, res string) []int {
+	if res == "-" {
+		return nil
+	}
+	var out []int
+	for _, pair := range strings.Split(res, " ") {
+		if pair == "-" {
+			out = append(out, -1, -1)
+			continue
+		}
+		lo, hi, _ := strings.Cut(pair, "-")
+		l, err1 := strconv.Atoi(lo)
+		h, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil {
+			t.Fatalf("invalid pair %q", pair)
+		}
+		out = append(out, l, h)
+	}
+	return out
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	for _, pattern := range []string{
+		`abc`, `(abc)`, `(?:abc)`, `a(b)c`, `abc|abd`, `ab|abd`, `a|b`, `abc+`, `a*b`,
+		`^abc`, `(?i)abc`, `ab.`, `é|è`, `日本|日本語`, `(?:ab|ac)d`, ``,
+	} {
+		prefix, complete := MustCompile(pattern).LiteralPrefix()
+		wantPrefix, wantComplete := regexp.MustCompile(pattern).LiteralPrefix()
+		if prefix != wantPrefix || complete != wantComplete {
+			t.Errorf("%q: got %q, %v, want %q, %v", pattern, prefix, complete, wantPrefix, wantComplete)
+		}
+	}
+}
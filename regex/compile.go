@@ -0,0 +1,241 @@
+package regex
+
+import "fmt"
+
+// maxInsts bounds program size, which counted repetition can inflate.
+const maxInsts = 100000
+
+type opcode uint8
+
+const (
+	opByte    opcode = iota // input byte equals b
+	opClass                 // input rune is in class
+	opAssert                // assertion arg holds; see assert
+	opBackref               // input continues with the text of group arg, ignoring case if fold
+	opSave                  // slots[arg] = pos
+	opSplit                 // try arg, then alt on failure
+	opJmp                   // continue at arg
+	opCheck                 // fail if slots[arg] == pos, else slots[arg] = pos; see genPlus
+	opMatch
+)
+
+type inst struct {
+	op    opcode
+	b     byte
+	fold  bool
+	class *class
+	arg   int
+	alt   int
+}
+
+// prog is a compiled pattern. Slots 2i and 2i+1 hold the bounds of group i
+// (group 0 is the whole match); loop registers used by opCheck follow.
+type prog struct {
+	insts []inst
+	ncap  int
+	nslot int
+	// emptyLoops is set if a loop's body can match the empty string
+	emptyLoops bool
+	anchored   bool
+	backrefs   bool
+	crlf       bool
+}
+
+type compiler struct {
+	pattern    string
+	insts      []inst
+	nextReg    int
+	emptyLoops bool
+	backrefs   bool
+}
+
+func compile(pattern string, n *node, ngroup int, opts Options) (*prog, error) {
+	c := &compiler{pattern: pattern, nextReg: 2 * (ngroup + 1)}
+	c.emit(inst{op: opSave, arg: 0})
+	if err := c.gen(n); err != nil {
+		return nil, err
+	}
+	c.emit(inst{op: opSave, arg: 1})
+	c.emit(inst{op: opMatch})
+	return &prog{
+		insts:      c.insts,
+		ncap:       ngroup + 1,
+		nslot:      c.nextReg,
+		emptyLoops: c.emptyLoops,
+		anchored:   startsWithBegin(n),
+		backrefs:   c.backrefs,
+		crlf:       opts.CRLF,
+	}, nil
+}
+
+// startsWithBegin reports whether every match of n must start at offset 0.
+func startsWithBegin(n *node) bool {
+	switch n.kind {
+	case nodeAssert:
+		return n.assert == assertBegin
+	case nodeGroup, nodeConcat:
+		return startsWithBegin(n.subs[0])
+	case nodeAlternate:
+		for _, s := range n.subs {
+			if !startsWithBegin(s) {
+				return false
+			}
+		}
+		return true
+	case nodeRepeat:
+		return n.min > 0 && startsWithBegin(n.subs[0])
+	}
+	return false
+}
+
+func (c *compiler) emit(i inst) int {
+	c.insts = append(c.insts, i)
+	return len(c.insts) - 1
+}
+
+func (c *compiler) gen(n *node) error {
+	if len(c.insts) > maxInsts {
+		return fmt.Errorf("parse %q: pattern too large", c.pattern)
+	}
+	switch n.kind {
+	case nodeEmpty:
+	case nodeLiteral:
+		c.emit(inst{op: opByte, b: n.b})
+	case nodeClass:
+		c.emit(inst{op: opClass, class: n.class})
+	case nodeAssert:
+		c.emit(inst{op: opAssert, arg: int(n.assert)})
+	case nodeBackref:
+		c.emit(inst{op: opBackref, arg: n.group, fold: n.fold})
+		c.backrefs = true
+	case nodeGroup:
+		c.emit(inst{op: opSave, arg: 2 * n.group})
+		if err := c.gen(n.subs[0]); err != nil {
+			return err
+		}
+		c.emit(inst{op: opSave, arg: 2*n.group + 1})
+	case nodeConcat:
+		for _, s := range n.subs {
+			if err := c.gen(s); err != nil {
+				return err
+			}
+		}
+	case nodeAlternate:
+		// split L1, next; L1: a; jmp end; next: split L2, next'; ... last alt
+		var jumps []int
+		for i, s := range n.subs {
+			split := -1
+			if i < len(n.subs)-1 {
+				split = c.emit(inst{op: opSplit})
+				c.insts[split].arg = len(c.insts)
+			}
+			if err := c.gen(s); err != nil {
+				return err
+			}
+			if split >= 0 {
+				jumps = append(jumps, c.emit(inst{op: opJmp}))
+				c.insts[split].alt = len(c.insts)
+			}
+		}
+		for _, j := range jumps {
+			c.insts[j].arg = len(c.insts)
+		}
+	case nodeRepeat:
+		return c.genRepeat(n)
+	}
+	return nil
+}
+
+func (c *compiler) genRepeat(n *node) error {
+	sub := n.subs[0]
+	if n.max < 0 {
+		if n.min == 0 {
+			return c.genStar(sub, n.lazy)
+		}
+		// x{n,} is x{n-1} followed by x+
+		for i := 1; i < n.min; i++ {
+			if err := c.gen(sub); err != nil {
+				return err
+			}
+		}
+		return c.genPlus(sub, n.lazy)
+	}
+	for i := 0; i < n.min; i++ {
+		if err := c.gen(sub); err != nil {
+			return err
+		}
+	}
+	// each optional copy is tried, or if lazy skipped, first and skips
+	// straight to the end
+	var splits []int
+	for i := n.min; i < n.max; i++ {
+		splits = append(splits, c.emit(inst{op: opSplit}))
+		if err := c.gen(sub); err != nil {
+			return err
+		}
+	}
+	for _, s := range splits {
+		c.patchSplit(s, s+1, len(c.insts), n.lazy)
+	}
+	return nil
+}
+
+// patchSplit makes the split at pc try body before exit, or exit first if
+// lazy.
+func (c *compiler) patchSplit(pc, body, exit int, lazy bool) {
+	if lazy {
+		body, exit = exit, body
+	}
+	c.insts[pc].arg, c.insts[pc].alt = body, exit
+}
+
+// genStar emits a loop over sub: a split between sub and the exit that sub
+// jumps back to or, when sub can match the empty string, (sub+)? so that
+// the first iteration is entered by a split of its own. These are the
+// layouts package regexp uses, and they decide which states a memoizing
+// match finds already visited.
+func (c *compiler) genStar(sub *node, lazy bool) error {
+	if sub.nullable() {
+		enter := c.emit(inst{op: opSplit})
+		if err := c.genPlus(sub, lazy); err != nil {
+			return err
+		}
+		c.patchSplit(enter, enter+1, len(c.insts), lazy)
+		return nil
+	}
+	loop := c.emit(inst{op: opSplit})
+	if err := c.gen(sub); err != nil {
+		return err
+	}
+	c.emit(inst{op: opJmp, arg: loop})
+	c.patchSplit(loop, loop+1, len(c.insts), lazy)
+	return nil
+}
+
+// genPlus emits sub followed by a split back to it, greedy unless lazy.
+// When sub can match the empty string, the iteration is bracketed by
+// opChecks, which stand for the states package regexp never revisits at
+// one offset: the start of the body, so that the loop stops after an empty
+// iteration, and the split, so that an empty iteration after the first
+// fails.
+func (c *compiler) genPlus(sub *node, lazy bool) error {
+	nullable := sub.nullable()
+	c.emptyLoops = c.emptyLoops || nullable
+	check := func() {
+		c.emit(inst{op: opCheck, arg: c.nextReg})
+		c.nextReg++
+	}
+	body := len(c.insts)
+	if nullable {
+		check()
+	}
+	if err := c.gen(sub); err != nil {
+		return err
+	}
+	if nullable {
+		check()
+	}
+	loop := c.emit(inst{op: opSplit})
+	c.patchSplit(loop, body, loop+1, lazy)
+	return nil
+}
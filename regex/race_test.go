@@ -0,0 +1,7 @@
+//go:build race
+
+package regex
+
+// raceEnabled is set when the race detector is on. It makes sync.Pool drop
+// items at random, so pooled machines get allocated again.
+const raceEnabled = true
@@ -0,0 +1,346 @@
+// Package regex is the backtracking regular expression engine behind mygrep.
+//
+// It accepts the syntax of package regexp: literals, ., the escapes \a, \f,
+// \t, \n, \r, \v, \0, \xHH, \x{H...} and \Q...\E, the classes \d, \w, \s,
+// \pN and \p{Name} with their negations, bracket classes with ranges,
+// negation and [:name:] classes, the assertions ^, $, \A, \z, \b and \B, the
+// quantifiers *, +, ?, {n}, {n,} and {n,m} and their lazy forms such as *?,
+// alternation, and the flags i, m, s and U set with (?flags) or
+// (?flags:...). Capturing groups are numbered by the position of their
+// opening paren and may be named with (?P<name>...) or (?<name>...);
+// (?:...) groups without capturing. Matching is over UTF-8: . and classes
+// match a whole character, and an empty match is followed by a search from
+// the next character, with offsets in bytes.
+//
+// It adds back-references \1-\9, so octal codes must begin with \0; under
+// (?i) they ignore case. Where package regexp reports an error, a
+// quantifier with nothing to repeat is taken literally and quantifiers may
+// be stacked, as in a**, as grep -E allows, and invalid UTF-8 in a pattern
+// matches itself. Group names must be unique. Patterns that repeat a group
+// which can match the empty string give the offsets package regexp gives
+// only while they are memoized, which back-references and inputs too long
+// for Options.Memoize prevent.
+//
+// Regexp has the method set of *regexp.Regexp, so code written against
+// package regexp can switch to this engine where it needs back-references.
+package regex
+
+import (
+	"errors"
+	"iter"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when a match runs out of the steps or time
+// allowed by its Options.
+var ErrBudgetExceeded = errors.New("match budget exceeded")
+
+// Options tune the backtracking engine. The zero value imposes no limits.
+type Options struct {
+	// MaxSteps bounds the instructions executed by the attempt to match at
+	// one starting offset, which is where backtracking can blow up; a match
+	// call makes an attempt at each offset in turn. Zero means unlimited.
+	MaxSteps int
+	// Timeout bounds the wall-clock time of one match call. Zero means
+	// unlimited.
+	Timeout time.Duration
+	// Memoize records every (instruction, offset) state explored so that
+	// none is explored twice, making matching polynomial in the input
+	// length. It is ignored for patterns with back-references, whose
+	// outcome depends on the captured text. Patterns that repeat something
+	// that can match the empty string are memoized regardless, as far as
+	// the input allows, since that gives their groups the offsets package
+	// regexp reports.
+	Memoize bool
+	// MultiLine lets ^ and $ match at the start and end of every line, not
+	// just of the input, as the flag m does, for searching text that holds
+	// many lines.
+	MultiLine bool
+	// CRLF treats "\r\n" as a line end: $ matches before a "\r" that ends
+	// the input and, with MultiLine, before every "\r\n", where . matches
+	// neither byte.
+	CRLF bool
+	// Longest prefers, among the matches that start leftmost, the longest
+	// one rather than the first the pattern's priorities reach, as POSIX
+	// requires. Groups hold the first way of matching that length.
+	Longest bool
+}
+
+// Regexp is a compiled pattern. It is safe for concurrent use. Its
+// methods take strings or, with the same names less "String", byte
+// slices, which are matched without being copied.
+type Regexp struct {
+	expr  string
+	prog  *prog
+	names []string
+	opts  Options
+	// machines holds idle machines, so that matching reuses their memory
+	machines sync.Pool
+}
+
+// Compile parses a pattern and returns the Regexp that matches it.
+func Compile(expr string) (*Regexp, error) {
+	return CompileOptions(expr, Options{})
+}
+
+// CompileOptions is like Compile but matches under the given options.
+func CompileOptions(expr string, opts Options) (*Regexp, error) {
+	n, ngroup, names, err := parse(expr, opts)
+	if err != nil {
+		return nil, err
+	}
+	p, err := compile(expr, n, ngroup, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Regexp{expr: expr, prog: p, names: names, opts: opts}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be parsed.
+func MustCompile(expr string) *Regexp {
+	re, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// String returns the source text of the pattern.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// NumSubexp returns the number of capturing groups in the pattern.
+func (re *Regexp) NumSubexp() int {
+	return re.prog.ncap - 1
+}
+
+// SubexpNames returns the names of the capturing groups: names[i] is the
+// name of group i, or "" if it is unnamed. names[0] is always "".
+func (re *Regexp) SubexpNames() []string {
+	return re.names
+}
+
+// SubexpIndex returns the number of the group with the given name, or -1
+// if there is none.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name != "" {
+		for i, n := range re.names {
+			if n == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// get returns a machine ready for a match call over input.
+func (re *Regexp) get(input string) *machine {
+	m, _ := re.machines.Get().(*machine)
+	if m == nil {
+		m = newMachine(re.prog)
+	}
+	m.reset(&re.opts, input)
+	return m
+}
+
+// put returns m to the pool once its result has been used.
+func (re *Regexp) put(m *machine) {
+	m.input = ""
+	re.machines.Put(m)
+}
+
+// MatchString reports whether s contains a match of the pattern. A match
+// that exceeds the budget is reported as no match; use TryMatchString to
+// tell the two apart.
+func (re *Regexp) MatchString(s string) bool {
+	ok, _ := re.TryMatchString(s)
+	return ok
+}
+
+// TryMatchString reports whether s contains a match of the pattern, or
+// ErrBudgetExceeded if that could not be decided within the budget.
+func (re *Regexp) TryMatchString(s string) (bool, error) {
+	m := re.get(s)
+	defer re.put(m)
+	ok := m.match(0)
+	return ok, m.err
+}
+
+// FindStringIndex returns the start and end offsets of the leftmost match
+// of the pattern in s, or nil if there is none.
+func (re *Regexp) FindStringIndex(s string) []int {
+	m := re.get(s)
+	defer re.put(m)
+	if !m.match(0) {
+		return nil
+	}
+	return []int{m.slots[0], m.slots[1]}
+}
+
+// FindString returns the text of the leftmost match of the pattern in s, or
+// "" if there is none; use FindStringIndex to tell that from an empty match.
+func (re *Regexp) FindString(s string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return ""
+	}
+	return s[loc[0]:loc[1]]
+}
+
+// FindStringSubmatchIndex returns the offsets of the leftmost match of the
+// pattern in s and of its capturing groups: 2i and 2i+1 are the bounds of
+// group i, or -1 if it did not participate. It returns nil if there is no
+// match.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	m := re.get(s)
+	defer re.put(m)
+	if !m.match(0) {
+		return nil
+	}
+	return append([]int(nil), m.slots[:2*re.prog.ncap]...)
+}
+
+// TryFindStringSubmatchIndexFrom returns the leftmost match of the pattern
+// in s that starts at offset from or later, laid out as by
+// FindStringSubmatchIndex, or nil if there is none; the text before from is
+// still seen by assertions such as \b. err is ErrBudgetExceeded if a match
+// could not be decided within the budget.
+//
+// It serves to search a long text a part at a time, s being a prefix of
+// it: partial is the first offset from which the text after s could give a
+// match, or a different one, or -1 if the result holds whatever follows s.
+// That is the first offset whose attempt looked at the end of s or, if
+// there was no match, at most len(s).
+func (re *Regexp) TryFindStringSubmatchIndexFrom(s string, from int) (loc []int, partial int, err error) {
+	m := re.get(s)
+	defer re.put(m)
+	if m.match(from) {
+		loc = append([]int(nil), m.slots[:2*re.prog.ncap]...)
+	}
+	return loc, m.partial, m.err
+}
+
+// FindStringSubmatch returns the text of the leftmost match of the pattern
+// in s and of its capturing groups, "" for a group that did not
+// participate, or nil if there is no match.
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil
+	}
+	return submatches(s, loc)
+}
+
+// submatches returns the text of each group bounded in loc.
+func submatches(s string, loc []int) []string {
+	out := make([]string, len(loc)/2)
+	for i := range out {
+		if loc[2*i] >= 0 {
+			out[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return out
+}
+
+// FindAllStringIndex returns the offsets of successive non-overlapping
+// matches in s, at most n of them if n >= 0. As in package regexp, an empty
+// match immediately after a previous match is ignored.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	var out [][]int
+	re.findAll(s, n, func(slots []int) bool {
+		out = append(out, []int{slots[0], slots[1]})
+		return true
+	})
+	return out
+}
+
+// FindAllString returns the text of successive non-overlapping matches in
+// s, at most n of them if n >= 0, as FindAllStringIndex finds them.
+func (re *Regexp) FindAllString(s string, n int) []string {
+	var out []string
+	re.findAll(s, n, func(slots []int) bool {
+		out = append(out, s[slots[0]:slots[1]])
+		return true
+	})
+	return out
+}
+
+// FindAllStringSubmatchIndex is like FindAllStringIndex but each match also
+// holds the offsets of the capturing groups: 2i and 2i+1 are the bounds of
+// group i, or -1 if it did not participate.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	var out [][]int
+	re.findAll(s, n, func(slots []int) bool {
+		out = append(out, append([]int(nil), slots[:2*re.prog.ncap]...))
+		return true
+	})
+	return out
+}
+
+// FindAllStringSubmatch is like FindAllStringSubmatchIndex but returns the
+// text of each match and its groups, as FindStringSubmatch does.
+func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	var out [][]string
+	re.findAll(s, n, func(slots []int) bool {
+		out = append(out, submatches(s, slots[:2*re.prog.ncap]))
+		return true
+	})
+	return out
+}
+
+// AllString returns an iterator over the text of successive non-overlapping
+// matches in s. Matches are found as the loop asks for them, so breaking
+// out early skips the rest of the search.
+func (re *Regexp) AllString(s string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		re.findAll(s, -1, func(slots []int) bool {
+			return yield(s[slots[0]:slots[1]])
+		})
+	}
+}
+
+// AllStringSubmatchIndex returns an iterator over the offsets of successive
+// non-overlapping matches in s and their capturing groups, laid out as in
+// FindStringSubmatchIndex. Each slice yielded is the caller's to keep.
+func (re *Regexp) AllStringSubmatchIndex(s string) iter.Seq[[]int] {
+	return func(yield func([]int) bool) {
+		re.findAll(s, -1, func(slots []int) bool {
+			return yield(append([]int(nil), slots[:2*re.prog.ncap]...))
+		})
+	}
+}
+
+// findAll calls deliver with the capture slots of each successive match
+// until it returns false.
+func (re *Regexp) findAll(s string, n int, deliver func(slots []int) bool) {
+	m := re.get(s)
+	defer re.put(m)
+	prevEnd := -1
+	for pos, count := 0, 0; pos <= len(s) && (n < 0 || count < n); {
+		// each match gets a budget of its own
+		m.reset(&re.opts, s)
+		if !m.match(pos) {
+			break
+		}
+		start, end := m.slots[0], m.slots[1]
+		accept := true
+		if end == pos {
+			// an empty match at pos: step over the rune after it
+			if start == prevEnd {
+				accept = false
+			}
+			pos += m.width(pos)
+		} else {
+			pos = end
+		}
+		prevEnd = end
+		if accept {
+			if !deliver(m.slots) {
+				return
+			}
+			count++
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package regex
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// These cases need the engine to backtrack into alternatives and
+// repetitions that a greedy matcher would have committed to.
+var submatchTests = []struct {
+	pattern, input string
+	want           []int
+}{
+	{`(a|ab)*c`, "abc", []int{0, 3, 0, 2}},
+	{`(a|ab)(c|bcd)(d*)`, "abcd", []int{0, 4, 0, 1, 1, 4, 4, 4}},
+	{`(ab|a)(bc|c)`, "abc", []int{0, 3, 0, 2, 2, 3}},
+	{`((a|b)c)+`, "acbcx", []int{0, 4, 2, 4, 2, 3}},
+	{`(a(b|c)*d)+`, "abcdad", []int{0, 6, 4, 6, 2, 3}},
+	{`(a+|b+)*c`, "aabbac", []int{0, 6, 4, 5}},
+	{`(x(y|z)?)+`, "xyxxz", []int{0, 5, 3, 5, 4, 5}},
+	{`(a{2,3})+`, "aaaaaaa", []int{0, 6, 3, 6}},
+	// a group keeps its last offsets when a later iteration skips it
+	{`((a)|(b))+`, "ab", []int{0, 2, 1, 2, 0, 1, 1, 2}},
+
+	// back-references
+	{`(\w+) \1`, "hello hello", []int{0, 11, 0, 5}},
+	{`(\w+) \1`, "hello help", nil},
+	{`(\d+)-\1`, "12-1", nil},
+	{`((\w)\2)+`, "aabbc", []int{0, 4, 2, 4, 2, 3}},
+	{`(a(b)\2)\1`, "abbabb", []int{0, 6, 0, 3, 1, 2}},
+	{`(a)?b\1`, "b", nil},
+	{`(a)?b\1`, "aba", []int{0, 3, 0, 1}},
+
+	// As in package regexp, only the first iteration of a loop may match
+	// the empty string, and its groups keep the offsets it set; a later
+	// empty iteration is dropped.
+	{`(a*)*`, "b", []int{0, 0, 0, 0}},
+	{`(a*)+`, "b", []int{0, 0, 0, 0}},
+	{`(|a)*`, "aa", []int{0, 0, 0, 0}},
+	{`(a|b|)*`, "ab", []int{0, 2, 1, 2}},
+	{`((a*)*b)*`, "abb", []int{0, 3, 2, 3, 2, 2}},
+}
+
+func TestFindStringSubmatchIndex(t *testing.T) {
+	for _, memo := range []bool{false, true} {
+		for _, tt := range submatchTests {
+			re, err := CompileOptions(tt.pattern, Options{Memoize: memo})
+			if err != nil {
+				t.Fatalf("CompileOptions(%q): %v", tt.pattern, err)
+			}
+			if got := re.FindStringSubmatchIndex(tt.input); !slices.Equal(got, tt.want) {
+				t.Errorf("%q on %q (memoize %v): got %v, want %v", tt.pattern, tt.input, memo, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestSearchPrefix checks that what a search of a prefix of the input
+// reports as final holds for the whole input, and that no match starts
+// before the offset it reports as partial. Prefixes end between runes.
+func TestSearchPrefix(t *testing.T) {
+	n := 3000
+	if testing.Short() {
+		n = 300
+	}
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < n; i++ {
+		pattern := randomPattern(r, 1+r.Intn(4))
+		re, err := CompileOptions(pattern, Options{MultiLine: r.Intn(2) == 0, CRLF: r.Intn(4) == 0})
+		if err != nil {
+			t.Fatalf("CompileOptions(%q): %v", pattern, err)
+		}
+		var b strings.Builder
+		for j := r.Intn(9); j > 0; j-- {
+			b.WriteString(randomChars[r.Intn(len(randomChars))])
+		}
+		b.WriteString([]string{"", "\r", "\r\n"}[r.Intn(3)])
+		s := b.String()
+		for from := 0; from <= len(s); from++ {
+			if from < len(s) && !utf8.RuneStart(s[from]) {
+				continue
+			}
+			want, _, _ := re.TryFindStringSubmatchIndexFrom(s, from)
+			for end := from; end < len(s); end++ {
+				if !utf8.RuneStart(s[end]) {
+					continue
+				}
+				got, partial, err := re.TryFindStringSubmatchIndexFrom(s[:end], from)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if partial < 0 && !slices.Equal(got, want) {
+					t.Fatalf("%q on %q from %d: prefix %q gave final %v, want %v", pattern, s, from, s[:end], got, want)
+				}
+				if partial >= 0 {
+					if rest, _, _ := re.TryFindStringSubmatchIndexFrom(s, partial); !slices.Equal(rest, want) {
+						t.Fatalf("%q on %q from %d: prefix %q gave partial %d, but from there %v, want %v", pattern, s, from, s[:end], partial, rest, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestBudget checks that MaxSteps and Timeout stop a match that would
+// backtrack for exponential time, and that Memoize bounds the steps of one
+// without back-references by the size of the input.
+func TestBudget(t *testing.T) {
+	runaway := strings.Repeat("a", 40)
+	for _, opts := range []Options{{MaxSteps: 100000}, {Timeout: 10 * time.Millisecond}} {
+		re := mustCompileOptions(t, `(\w+)*\1x`, opts)
+		start := time.Now()
+		if _, err := re.TryMatchString(runaway); err != ErrBudgetExceeded {
+			t.Errorf("%+v: got %v, want ErrBudgetExceeded", opts, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%+v: stopped after %v", opts, d)
+		}
+	}
+
+	long := strings.Repeat("a", 5000)
+	steps := 20 * len(long)
+	if _, err := mustCompileOptions(t, `(a|aa)*c`, Options{MaxSteps: steps}).TryMatchString(long); err != ErrBudgetExceeded {
+		t.Errorf("without Memoize: got %v, want ErrBudgetExceeded", err)
+	}
+	ok, err := mustCompileOptions(t, `(a|aa)*c`, Options{MaxSteps: steps, Memoize: true}).TryMatchString(long)
+	if ok || err != nil {
+		t.Errorf("with Memoize: got %v, %v, want no match within %d steps", ok, err, steps)
+	}
+}
+
+func mustCompileOptions(t *testing.T, expr string, opts Options) *Regexp {
+	t.Helper()
+	re, err := CompileOptions(expr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return re
+}
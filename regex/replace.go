@@ -0,0 +1,129 @@
+package regex
+
+import "strings"
+
+// ReplaceAllString returns a copy of src in which every match of the
+// pattern is replaced by repl, with $ signs expanded as by ExpandString.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	return re.replaceAll(src, func(dst []byte, match []int) []byte {
+		return re.ExpandString(dst, repl, src, match)
+	})
+}
+
+// ReplaceAllLiteralString is like ReplaceAllString but inserts repl as is,
+// without expanding $ signs.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return re.replaceAll(src, func(dst []byte, _ []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllStringFunc returns a copy of src in which every match is
+// replaced by the result of repl applied to the matched text.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	return re.replaceAll(src, func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+}
+
+// ReplaceAll is ReplaceAllString for byte slices.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	s := string(src)
+	return []byte(re.replaceAll(s, func(dst []byte, match []int) []byte {
+		return re.ExpandString(dst, string(repl), s, match)
+	}))
+}
+
+// ReplaceAllFunc is ReplaceAllStringFunc for byte slices.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return []byte(re.replaceAll(string(src), func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	}))
+}
+
+// replaceAll copies src with each match, as delivered by findAll, replaced
+// by what repl appends for it.
+func (re *Regexp) replaceAll(src string, repl func(dst []byte, match []int) []byte) string {
+	var buf []byte
+	last, matched := 0, false
+	re.findAll(src, -1, func(slots []int) bool {
+		buf = append(buf, src[last:slots[0]]...)
+		buf = repl(buf, slots[:2*re.prog.ncap])
+		last, matched = slots[1], true
+		return true
+	})
+	if !matched {
+		return src
+	}
+	return string(append(buf, src[last:]...))
+}
+
+// ExpandString appends template to dst with each $ reference replaced by
+// the text of the group it names in src, where match holds the group
+// offsets as returned by FindAllStringSubmatchIndex. $1 and ${1} refer to
+// groups by number, $name and ${name} by name, and $$ is a literal $. In
+// the unbraced form the name is as long as possible, so $1x is ${1x}, not
+// ${1}x. A reference to a group that doesn't exist or didn't participate
+// expands to nothing.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, template[:i]...)
+		template = template[i:]
+		if len(template) > 1 && template[1] == '$' {
+			dst = append(dst, '$')
+			template = template[2:]
+			continue
+		}
+		name, rest, ok := extractRef(template)
+		if !ok {
+			// a malformed reference is copied literally
+			dst = append(dst, '$')
+			template = template[1:]
+			continue
+		}
+		template = rest
+		if g := re.groupIndex(name); g >= 0 && 2*g+1 < len(match) && match[2*g] >= 0 {
+			dst = append(dst, src[match[2*g]:match[2*g+1]]...)
+		}
+	}
+	return append(dst, template...)
+}
+
+// groupIndex resolves a reference from a template: a number or a name.
+func (re *Regexp) groupIndex(name string) int {
+	num := 0
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' || num >= 1e8 {
+			return re.SubexpIndex(name)
+		}
+		num = num*10 + int(name[i]-'0')
+	}
+	return num
+}
+
+// extractRef parses the reference at the start of template, which begins
+// with '$'.
+func extractRef(template string) (name, rest string, ok bool) {
+	if len(template) < 2 {
+		return "", "", false
+	}
+	if template[1] == '{' {
+		end := strings.IndexByte(template, '}')
+		if end < 0 || !isGroupName(template[2:end]) {
+			return "", "", false
+		}
+		return template[2:end], template[end+1:], true
+	}
+	i := 1
+	for i < len(template) && isWordByte(template[i]) {
+		i++
+	}
+	if i == 1 {
+		return "", "", false
+	}
+	return template[1:i], template[i:], true
+}
@@ -0,0 +1,65 @@
+package regex
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// replaceTests are run through each ReplaceAll method and compared with
+// package regexp.
+var replaceTests = []struct {
+	pattern, repl, input string
+}{
+	{`a+`, "", "aaa"},
+	{`\s+`, "", "   "},
+	{`a`, "", "banana"},
+	{`x*`, "-", "abc"},
+	{`(\w+)@(\w+)`, "$2 at $1", "joe@example, ann@test"},
+	{`(\w+)`, "$1x", "ab cd"},
+	{`(\w+)`, "${1}x", "ab cd"},
+	{`(?P<word>\w+)`, "<${word}>", "ab cd"},
+	{`(?P<word>\w+)`, "<$word>", "ab cd"},
+	{`\d+`, "$$", "a1b22"},
+	{`(a)|(b)`, "[$1|$2]", "abc"},
+	{`(\w)`, "$9", "ab"},
+	{`\w`, "$", "ab"},
+	{`\w`, "${1", "ab"},
+	{`b`, "B", "aaa"},
+	{``, "-", "日本"},
+}
+
+func TestReplaceAll(t *testing.T) {
+	upper := func(s string) string { return strings.ToUpper(s) + "!" }
+	for _, tt := range replaceTests {
+		re, want := MustCompile(tt.pattern), regexp.MustCompile(tt.pattern)
+		for _, c := range []struct {
+			method    string
+			got, want string
+		}{
+			{"ReplaceAllString", re.ReplaceAllString(tt.input, tt.repl), want.ReplaceAllString(tt.input, tt.repl)},
+			{"ReplaceAllLiteralString", re.ReplaceAllLiteralString(tt.input, tt.repl), want.ReplaceAllLiteralString(tt.input, tt.repl)},
+			{"ReplaceAllStringFunc", re.ReplaceAllStringFunc(tt.input, upper), want.ReplaceAllStringFunc(tt.input, upper)},
+			{"ReplaceAll", string(re.ReplaceAll([]byte(tt.input), []byte(tt.repl))), string(want.ReplaceAll([]byte(tt.input), []byte(tt.repl)))},
+			{"ReplaceAllLiteral", string(re.ReplaceAllLiteral([]byte(tt.input), []byte(tt.repl))), string(want.ReplaceAllLiteral([]byte(tt.input), []byte(tt.repl)))},
+			{"ReplaceAllFunc", string(re.ReplaceAllFunc([]byte(tt.input), bytes.ToUpper)), string(want.ReplaceAllFunc([]byte(tt.input), bytes.ToUpper))},
+		} {
+			if c.got != c.want {
+				t.Errorf("%s(%q, %q) with %q: got %q, want %q", c.method, tt.input, tt.repl, tt.pattern, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestExpandString(t *testing.T) {
+	for _, tt := range replaceTests {
+		re, want := MustCompile(tt.pattern), regexp.MustCompile(tt.pattern)
+		for _, m := range want.FindAllStringSubmatchIndex(tt.input, -1) {
+			got, want := re.ExpandString([]byte("> "), tt.repl, tt.input, m), want.ExpandString([]byte("> "), tt.repl, tt.input, m)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%q on %q with %q at %v: got %q, want %q", tt.repl, tt.input, tt.pattern, m, got, want)
+			}
+		}
+	}
+}
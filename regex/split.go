@@ -0,0 +1,52 @@
+package regex
+
+// Split slices s into the substrings between the matches of the pattern,
+// at most n of them if n > 0, the last holding the unsplit rest; n == 0
+// returns nil. As in package regexp, an empty match at the start or end of
+// s doesn't produce an empty substring there.
+func (re *Regexp) Split(s string, n int) []string {
+	return re.split(s, n, false)
+}
+
+// SplitSubmatch is like Split but keeps the text of the capturing groups
+// of each separator, between the substrings it separates, with "" for a
+// group that did not participate. With no groups it is Split.
+func (re *Regexp) SplitSubmatch(s string, n int) []string {
+	return re.split(s, n, true)
+}
+
+func (re *Regexp) split(s string, n int, groups bool) []string {
+	if n == 0 {
+		return nil
+	}
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+	matches := re.FindAllStringSubmatchIndex(s, n)
+	out := make([]string, 0, len(matches)+1)
+	pieces := 0
+	beg, end := 0, 0
+	// the groups of a separator go in only once the substring after it
+	// does, so that an empty match at either end adds none
+	var sep []string
+	for _, m := range matches {
+		if n > 0 && pieces == n-1 {
+			break
+		}
+		end = m[0]
+		if m[1] != 0 {
+			out = append(out, sep...)
+			out = append(out, s[beg:end])
+			pieces++
+			if groups {
+				sep = submatches(s, m)[1:]
+			}
+		}
+		beg = m[1]
+	}
+	if end != len(s) {
+		out = append(out, sep...)
+		out = append(out, s[beg:])
+	}
+	return out
+}
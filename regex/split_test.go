@@ -0,0 +1,54 @@
+package regex
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+var splitTests = []struct {
+	pattern string
+	inputs  []string
+}{
+	{`,`, []string{"a,b,c", ",a,", "", ","}},
+	{`\s*`, []string{"a b  c", " ab "}},
+	{`x*`, []string{"axbxxc", "日本"}},
+	{``, []string{"abc", ""}},
+	{`(a)|(b)`, []string{"1a2b3"}},
+	{`$`, []string{"ab"}},
+	{`^`, []string{"ab"}},
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range splitTests {
+		re, want := MustCompile(tt.pattern), regexp.MustCompile(tt.pattern)
+		for _, s := range tt.inputs {
+			for _, n := range []int{-1, 0, 1, 2, 3} {
+				if got, want := re.Split(s, n), want.Split(s, n); !slices.Equal(got, want) {
+					t.Errorf("%q on %q, n = %d: got %q, want %q", tt.pattern, s, n, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSplitSubmatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, input string
+		n              int
+		want           []string
+	}{
+		{`(,)`, "a,b,c", -1, []string{"a", ",", "b", ",", "c"}},
+		{`(,)`, "a,b,c", 0, nil},
+		{`(,)`, "a,b,c", 1, []string{"a,b,c"}},
+		{`(,)`, "a,b,c", 2, []string{"a", ",", "b,c"}},
+		// a group that did not take part gives ""
+		{`(-)|(\+)`, "1-2+3", -1, []string{"1", "-", "", "2", "", "+", "3"}},
+		{`(x*)`, "ab", -1, []string{"a", "", "b"}},
+		{`,`, "a,b", -1, []string{"a", "b"}},
+	} {
+		if got := MustCompile(tt.pattern).SplitSubmatch(tt.input, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("%q on %q, n = %d: got %q, want %q", tt.pattern, tt.input, tt.n, got, tt.want)
+		}
+	}
+}
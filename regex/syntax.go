@@ -0,0 +1,712 @@
+package regex
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRepeat bounds the counts accepted in {n}, {n,} and {n,m}.
+const maxRepeat = 1000
+
+type nodeKind uint8
+
+const (
+	nodeEmpty     nodeKind = iota // matches the empty string
+	nodeLiteral                   // a single byte
+	nodeClass                     // a set of runes: ., \d, \pL, [...]
+	nodeAssert                    // an empty-width assertion: ^, $, \b, ...
+	nodeBackref                   // \1-\9
+	nodeGroup                     // (...)
+	nodeConcat                    // subs in sequence
+	nodeAlternate                 // subs in priority order
+	nodeRepeat                    // subs[0] repeated min..max times, max < 0 is unbounded
+)
+
+// assertion is the condition a nodeAssert tests.
+type assertion uint8
+
+const (
+	assertBegin          assertion = iota // ^ or \A: start of input
+	assertBeginLine                       // ^ in multi-line mode
+	assertEnd                             // $: end of input; see atEnd
+	assertEndLine                         // $ in multi-line mode
+	assertEndText                         // \z: end of input, even with CRLF
+	assertWordBoundary                    // \b
+	assertNoWordBoundary                  // \B
+)
+
+type node struct {
+	kind     nodeKind
+	b        byte
+	class    *class
+	assert   assertion
+	group    int
+	min, max int
+	// lazy repeats try fewer iterations first; fold makes a back-reference
+	// ignore case
+	lazy, fold bool
+	subs       []*node
+}
+
+// nullable reports whether n can match without consuming input.
+func (n *node) nullable() bool {
+	switch n.kind {
+	case nodeLiteral, nodeClass:
+		return false
+	case nodeGroup:
+		return n.subs[0].nullable()
+	case nodeConcat:
+		for _, s := range n.subs {
+			if !s.nullable() {
+				return false
+			}
+		}
+		return true
+	case nodeAlternate:
+		for _, s := range n.subs {
+			if s.nullable() {
+				return true
+			}
+		}
+		return false
+	case nodeRepeat:
+		return n.min == 0 || n.subs[0].nullable()
+	}
+	// empty, assertions and back-references (the group may have captured "")
+	return true
+}
+
+// flags change how the rest of a group is parsed; (?flags) sets them.
+type flags uint8
+
+const (
+	flagFold      flags = 1 << iota // i: letters match either case
+	flagMultiLine                   // m: ^ and $ match at line breaks
+	flagDotNL                       // s: . matches \n
+	flagUngreedy                    // U: x* is lazy and x*? greedy
+)
+
+var (
+	anyClass   = newClass(runeRanges{0, unicode.MaxRune})
+	notNLClass = newClass(runeRanges{0, '\n' - 1, '\n' + 1, unicode.MaxRune})
+	// notCRLFClass is . in multi-line mode with CRLF
+	notCRLFClass = newClass(runeRanges{0, '\n' - 1, '\n' + 1, '\r' - 1, '\r' + 1, unicode.MaxRune})
+)
+
+type parser struct {
+	src     string
+	pos     int
+	ngroup  int
+	maxBref int
+	names   []string
+	// flags are those in effect at pos; with crlf, . in multi-line mode
+	// matches neither byte of a line end
+	flags flags
+	crlf  bool
+}
+
+// parse turns a pattern into a node tree and returns the number of
+// capturing groups, numbered by the position of their opening paren, and
+// their names: names[i] is the name of group i, or "" if it has none.
+func parse(pattern string, opts Options) (n *node, ngroup int, names []string, err error) {
+	p := &parser{src: pattern, names: []string{""}, crlf: opts.CRLF}
+	if opts.MultiLine {
+		p.flags |= flagMultiLine
+	}
+	if n, err = p.parseAlternate(); err != nil {
+		return nil, 0, nil, err
+	}
+	if p.pos < len(p.src) {
+		// parseAlternate only stops early on an unbalanced ')'
+		return nil, 0, nil, p.errorf("unmatched )")
+	}
+	if p.maxBref > p.ngroup {
+		return nil, 0, nil, p.errorf("invalid back reference \\%d", p.maxBref)
+	}
+	return n, p.ngroup, p.names, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("parse %q: %s", p.src, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseAlternate() (*node, error) {
+	var alts []*node
+	for {
+		n, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, n)
+		if p.pos < len(p.src) && p.src[p.pos] == '|' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &node{kind: nodeAlternate, subs: alts}, nil
+}
+
+func (p *parser) parseConcat() (*node, error) {
+	var items []*node
+	for p.pos < len(p.src) && p.src[p.pos] != '|' && p.src[p.pos] != ')' {
+		var n *node
+		if strings.HasPrefix(p.src[p.pos:], `\Q`) {
+			// a quantifier after \Q...\E repeats its last character only
+			lits := p.parseQuoted()
+			if len(lits) == 0 {
+				continue
+			}
+			items = append(items, lits[:len(lits)-1]...)
+			n = lits[len(lits)-1]
+		} else {
+			var err error
+			if n, err = p.parseAtom(); err != nil {
+				return nil, err
+			}
+			if n == nil {
+				// (?flags) only changes how the rest is parsed
+				continue
+			}
+		}
+		n, err := p.parseQuantifiers(n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+	}
+	switch len(items) {
+	case 0:
+		return &node{kind: nodeEmpty}, nil
+	case 1:
+		return items[0], nil
+	}
+	return &node{kind: nodeConcat, subs: items}, nil
+}
+
+// parseAtom parses one item of a concatenation. It returns nil for a
+// (?flags) group, which matches nothing.
+func (p *parser) parseAtom() (*node, error) {
+	c := p.src[p.pos]
+	switch c {
+	case '(':
+		p.pos++
+		if p.pos < len(p.src) && p.src[p.pos] == '?' && !p.atGroupName() {
+			return p.parseFlags()
+		}
+		name, err := p.parseGroupName()
+		if err != nil {
+			return nil, err
+		}
+		p.ngroup++
+		group := p.ngroup
+		p.names = append(p.names, name)
+		sub, err := p.parseGroupBody()
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nodeGroup, group: group, subs: []*node{sub}}, nil
+	case '[':
+		return p.parseClass()
+	case '.':
+		p.pos++
+		switch {
+		case p.flags&flagDotNL != 0:
+			return &node{kind: nodeClass, class: anyClass}, nil
+		case p.flags&flagMultiLine != 0 && p.crlf:
+			return &node{kind: nodeClass, class: notCRLFClass}, nil
+		}
+		return &node{kind: nodeClass, class: notNLClass}, nil
+	case '^':
+		p.pos++
+		if p.flags&flagMultiLine != 0 {
+			return &node{kind: nodeAssert, assert: assertBeginLine}, nil
+		}
+		return &node{kind: nodeAssert, assert: assertBegin}, nil
+	case '$':
+		p.pos++
+		if p.flags&flagMultiLine != 0 {
+			return &node{kind: nodeAssert, assert: assertEndLine}, nil
+		}
+		return &node{kind: nodeAssert, assert: assertEnd}, nil
+	case '\\':
+		return p.parseEscape()
+	}
+	// a quantifier with nothing to repeat is taken literally, as in grep -E
+	start := p.pos
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	return p.literal(r, p.src[start:p.pos]), nil
+}
+
+// literal returns the node for rune r, spelt s in UTF-8: its bytes in
+// sequence or, when folding case, the class of the runes it folds to.
+func (p *parser) literal(r rune, s string) *node {
+	if p.flags&flagFold != 0 && unicode.SimpleFold(r) != r {
+		return &node{kind: nodeClass, class: newClass(p.ranges(runeRanges{r, r}, false))}
+	}
+	if len(s) == 1 {
+		return &node{kind: nodeLiteral, b: s[0]}
+	}
+	n := &node{kind: nodeConcat}
+	for i := 0; i < len(s); i++ {
+		n.subs = append(n.subs, &node{kind: nodeLiteral, b: s[i]})
+	}
+	return n
+}
+
+// parseQuoted parses \Q...\E, or \Q to the end of the pattern, into the
+// literals of the text between.
+func (p *parser) parseQuoted() []*node {
+	p.pos += 2 // `\Q`
+	text := p.src[p.pos:]
+	if end := strings.Index(text, `\E`); end >= 0 {
+		text = text[:end]
+		p.pos += 2
+	}
+	p.pos += len(text)
+	var lits []*node
+	for text != "" {
+		r, size := utf8.DecodeRuneInString(text)
+		lits = append(lits, p.literal(r, text[:size]))
+		text = text[size:]
+	}
+	return lits
+}
+
+// parseGroupBody parses the alternatives of a group and its closing paren.
+// Flags set within the group end with it.
+func (p *parser) parseGroupBody() (*node, error) {
+	saved := p.flags
+	sub, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("missing closing )")
+	}
+	p.pos++
+	p.flags = saved
+	return sub, nil
+}
+
+// parseFlags parses the rest of a group that begins "(?" and is not named:
+// (?flags), which sets flags for the rest of the enclosing group and
+// returns nil, or (?flags:...), which groups its alternatives under the
+// flags without capturing. The flags are i, m, s and U; those after a '-'
+// are cleared.
+func (p *parser) parseFlags() (*node, error) {
+	start := p.pos - 1
+	p.pos++ // '?'
+	f, negate, sawFlag := p.flags, false, false
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		var flag flags
+		switch c {
+		case 'i':
+			flag = flagFold
+		case 'm':
+			flag = flagMultiLine
+		case 's':
+			flag = flagDotNL
+		case 'U':
+			flag = flagUngreedy
+		case '-':
+			if negate {
+				return nil, p.errorf("invalid or unsupported group %s", p.src[start:p.pos])
+			}
+			negate, sawFlag = true, false
+			continue
+		case ':', ')':
+			if negate && !sawFlag {
+				return nil, p.errorf("invalid or unsupported group %s", p.src[start:p.pos])
+			}
+			if c == ')' {
+				p.flags = f
+				return nil, nil
+			}
+			saved := p.flags
+			p.flags = f
+			sub, err := p.parseGroupBody()
+			p.flags = saved
+			return sub, err
+		default:
+			return nil, p.errorf("invalid or unsupported group %s", p.src[start:p.pos])
+		}
+		if negate {
+			f &^= flag
+		} else {
+			f |= flag
+		}
+		sawFlag = true
+	}
+	return nil, p.errorf("missing closing )")
+}
+
+// atGroupName reports whether the '(' before p.pos opens a named group,
+// rather than a lookbehind, which is not supported.
+func (p *parser) atGroupName() bool {
+	rest := p.src[p.pos:]
+	return strings.HasPrefix(rest, "?P<") ||
+		strings.HasPrefix(rest, "?<") && !strings.HasPrefix(rest, "?<=") && !strings.HasPrefix(rest, "?<!")
+}
+
+// parseGroupName parses the "?P<name>" or "?<name>" that may follow a '('.
+func (p *parser) parseGroupName() (string, error) {
+	rest := p.src[p.pos:]
+	var prefix int
+	switch {
+	case strings.HasPrefix(rest, "?P<"):
+		prefix = 3
+	case strings.HasPrefix(rest, "?<"):
+		prefix = 2
+	default:
+		return "", nil
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", p.errorf("missing > in group name")
+	}
+	name := rest[prefix:end]
+	if !isGroupName(name) {
+		return "", p.errorf("invalid group name %q", name)
+	}
+	if slices.Contains(p.names, name) {
+		return "", p.errorf("duplicate group name %q", name)
+	}
+	p.pos += end + 1
+	return name, nil
+}
+
+func isGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isWordByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEscape parses an escape outside brackets: a back-reference, an
+// assertion, or one of the escapes parseEscapeRune knows.
+func (p *parser) parseEscape() (*node, error) {
+	if p.pos+1 < len(p.src) {
+		e := p.src[p.pos+1]
+		if e >= '1' && e <= '9' {
+			p.pos += 2
+			g := int(e - '0')
+			p.maxBref = max(p.maxBref, g)
+			return &node{kind: nodeBackref, group: g, fold: p.flags&flagFold != 0}, nil
+		}
+		if a, ok := escapeAssertions[e]; ok {
+			p.pos += 2
+			return &node{kind: nodeAssert, assert: a}, nil
+		}
+	}
+	r, rs, err := p.parseEscapeRune()
+	if err != nil {
+		return nil, err
+	}
+	if rs != nil {
+		return &node{kind: nodeClass, class: newClass(rs)}, nil
+	}
+	return p.literal(r, string(r)), nil
+}
+
+var escapeAssertions = map[byte]assertion{
+	'A': assertBegin,
+	'z': assertEndText,
+	'b': assertWordBoundary,
+	'B': assertNoWordBoundary,
+}
+
+// parseEscapeRune parses an escape that may appear inside brackets as well
+// as outside: it stands for the rune r or, for a class such as \d or \pL,
+// for the runes in rs. Punctuation escapes stand for themselves; letters
+// and digits that are not escapes are errors.
+func (p *parser) parseEscapeRune() (r rune, rs runeRanges, err error) {
+	start := p.pos
+	if p.pos+1 >= len(p.src) {
+		return 0, nil, p.errorf("trailing backslash")
+	}
+	e := p.src[p.pos+1]
+	p.pos += 2
+	switch e {
+	case 'a':
+		return '\a', nil, nil
+	case 'f':
+		return '\f', nil, nil
+	case 'n':
+		return '\n', nil, nil
+	case 'r':
+		return '\r', nil, nil
+	case 't':
+		return '\t', nil, nil
+	case 'v':
+		return '\v', nil, nil
+	case '0':
+		// \1-\9 are back-references, so octal codes start with \0
+		for i := 0; i < 2 && p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '7'; i++ {
+			r = r*8 + rune(p.src[p.pos]-'0')
+			p.pos++
+		}
+		return r, nil, nil
+	case 'x':
+		return p.parseHex(start)
+	case 'd', 'D':
+		return 0, p.ranges(digitRanges, e == 'D'), nil
+	case 's', 'S':
+		return 0, p.ranges(spaceRanges, e == 'S'), nil
+	case 'w', 'W':
+		return 0, p.ranges(wordRanges, e == 'W'), nil
+	case 'p', 'P':
+		return p.parseUnicodeClass(start, e == 'P')
+	}
+	if e < utf8.RuneSelf && (e == '_' || !isWordByte(e)) {
+		return rune(e), nil, nil
+	}
+	if e >= utf8.RuneSelf {
+		_, size := utf8.DecodeRuneInString(p.src[start+1:])
+		p.pos = start + 1 + size
+	}
+	return 0, nil, p.errorf("invalid escape %s", p.src[start:p.pos])
+}
+
+// parseHex parses the digits of the \xHH or \x{H...} escape at start.
+func (p *parser) parseHex(start int) (rune, runeRanges, error) {
+	var digits string
+	switch rest := p.src[p.pos:]; {
+	case strings.HasPrefix(rest, "{"):
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return 0, nil, p.errorf("missing } in %s", p.src[start:])
+		}
+		digits = rest[1:end]
+		p.pos += end + 1
+	case len(rest) >= 2:
+		digits = rest[:2]
+		p.pos += 2
+	default:
+		return 0, nil, p.errorf("invalid escape %s", p.src[start:])
+	}
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || v > unicode.MaxRune {
+		return 0, nil, p.errorf("invalid escape %s", p.src[start:p.pos])
+	}
+	return rune(v), nil, nil
+}
+
+// parseUnicodeClass parses the name of the \pN, \p{Name} or \p{^Name}
+// escape at start, a Unicode category or script, or Any.
+func (p *parser) parseUnicodeClass(start int, negate bool) (rune, runeRanges, error) {
+	rest := p.src[p.pos:]
+	var name string
+	switch {
+	case rest == "":
+		return 0, nil, p.errorf("invalid escape %s", p.src[start:])
+	case rest[0] == '{':
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return 0, nil, p.errorf("missing } in %s", p.src[start:])
+		}
+		name = rest[1:end]
+		p.pos += end + 1
+	default:
+		_, size := utf8.DecodeRuneInString(rest)
+		name = rest[:size]
+		p.pos += size
+	}
+	if strings.HasPrefix(name, "^") {
+		name, negate = name[1:], !negate
+	}
+	rs, ok := unicodeClass(name)
+	if !ok {
+		return 0, nil, p.errorf("invalid character class %s", p.src[start:p.pos])
+	}
+	return 0, p.ranges(rs, negate), nil
+}
+
+// ranges returns rs, with its case variants when folding, cleaned and, if
+// negate is set, complemented.
+func (p *parser) ranges(rs runeRanges, negate bool) runeRanges {
+	rs = slices.Clone(rs)
+	if p.flags&flagFold != 0 {
+		rs.addFolded()
+	}
+	rs = rs.clean()
+	if negate {
+		rs = rs.negate()
+	}
+	return rs
+}
+
+func (p *parser) parseClass() (*node, error) {
+	start := p.pos
+	p.pos++ // '['
+	var rs runeRanges
+	negate := false
+	if p.pos < len(p.src) && p.src[p.pos] == '^' {
+		negate = true
+		p.pos++
+	}
+	first := true
+	for {
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("missing closing ] for class at offset %d", start)
+		}
+		if p.src[p.pos] == ']' && !first {
+			p.pos++
+			break
+		}
+		first = false
+		if strings.HasPrefix(p.src[p.pos:], "[:") {
+			named, ok, err := p.parsePOSIXClass()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rs.addRanges(named)
+				continue
+			}
+		}
+		itemStart := p.pos
+		lo, item, err := p.parseClassRune()
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			rs.addRanges(item)
+			continue
+		}
+		// range a-z, unless the '-' is the last character of the class
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+			p.pos++
+			hi, item, err := p.parseClassRune()
+			if err != nil {
+				return nil, err
+			}
+			if item != nil || hi < lo {
+				return nil, p.errorf("invalid range %s", p.src[itemStart:p.pos])
+			}
+			rs.add(lo, hi)
+			continue
+		}
+		rs.add(lo, lo)
+	}
+	return &node{kind: nodeClass, class: newClass(p.ranges(rs, negate))}, nil
+}
+
+// parseClassRune parses one character in brackets: a rune, or an escape
+// that stands for a rune or for a class such as \d.
+func (p *parser) parseClassRune() (rune, runeRanges, error) {
+	if p.src[p.pos] == '\\' {
+		return p.parseEscapeRune()
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	return r, nil, nil
+}
+
+// parsePOSIXClass parses the [:name:] or [:^name:] class at p.pos. ok is
+// false, and p.pos unchanged, when the text is not one, and the '[' is
+// then a literal.
+func (p *parser) parsePOSIXClass() (rs runeRanges, ok bool, err error) {
+	end := strings.Index(p.src[p.pos+2:], ":]")
+	if end < 0 {
+		return nil, false, nil
+	}
+	text := p.src[p.pos : p.pos+2+end+2]
+	name, negate := strings.CutPrefix(text[2:len(text)-2], "^")
+	named, found := posixClasses[name]
+	if !found {
+		return nil, false, p.errorf("invalid character class %s", text)
+	}
+	p.pos += len(text)
+	return p.ranges(named, negate), true, nil
+}
+
+func (p *parser) parseQuantifiers(n *node) (*node, error) {
+	for p.pos < len(p.src) {
+		min, max := 0, 0
+		switch p.src[p.pos] {
+		case '*':
+			min, max = 0, -1
+			p.pos++
+		case '+':
+			min, max = 1, -1
+			p.pos++
+		case '?':
+			min, max = 0, 1
+			p.pos++
+		case '{':
+			var ok bool
+			var err error
+			if min, max, ok, err = p.parseBraces(); err != nil {
+				return nil, err
+			}
+			if !ok {
+				// not a valid interval: '{' is a literal and is parsed next
+				return n, nil
+			}
+		default:
+			return n, nil
+		}
+		// a '?' after the quantifier makes it lazy, and (?U) the reverse
+		lazy := p.pos < len(p.src) && p.src[p.pos] == '?'
+		if lazy {
+			p.pos++
+		}
+		n = &node{kind: nodeRepeat, min: min, max: max, lazy: lazy != (p.flags&flagUngreedy != 0), subs: []*node{n}}
+	}
+	return n, nil
+}
+
+// parseBraces parses {n}, {n,} or {n,m} at p.pos. ok is false, and p.pos
+// unchanged, when the text is not an interval, as with {,m}.
+func (p *parser) parseBraces() (min, max int, ok bool, err error) {
+	i := p.pos + 1
+	readNum := func() (int, bool) {
+		start, v := i, 0
+		for i < len(p.src) && p.src[i] >= '0' && p.src[i] <= '9' {
+			if v <= maxRepeat {
+				v = v*10 + int(p.src[i]-'0')
+			}
+			i++
+		}
+		return v, i > start
+	}
+	min, hasMin := readNum()
+	if !hasMin {
+		return 0, 0, false, nil
+	}
+	max = min
+	if i < len(p.src) && p.src[i] == ',' {
+		i++
+		var hasMax bool
+		if max, hasMax = readNum(); !hasMax {
+			max = -1
+		}
+	}
+	if i >= len(p.src) || p.src[i] != '}' {
+		return 0, 0, false, nil
+	}
+	if min > maxRepeat || max > maxRepeat {
+		return 0, 0, false, p.errorf("repetition count exceeds %d", maxRepeat)
+	}
+	if max >= 0 && max < min {
+		return 0, 0, false, p.errorf("invalid repetition {%d,%d}", min, max)
+	}
+	p.pos = i + 1
+	return min, max, true, nil
+}
@@ -0,0 +1,90 @@
+package regex
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+)
+
+// ErrNoToken is returned by a Tokenizer when no rule matches at the
+// current position.
+var ErrNoToken = errors.New("no token matches")
+
+// TokenRule names the pattern of one kind of token.
+type TokenRule struct {
+	Name    string
+	Pattern string
+}
+
+// Token is a piece of text matched by a TokenRule; Start and End are its
+// offsets in the tokenized string.
+type Token struct {
+	Name  string
+	Text  string
+	Start int
+	End   int
+}
+
+// Tokenizer splits text into tokens by matching its rules, in turn, at the
+// position where the previous token ended. It is safe for concurrent use.
+type Tokenizer struct {
+	names []string
+	res   []*Regexp
+}
+
+// NewTokenizer compiles the patterns of rules into a Tokenizer.
+func NewTokenizer(rules []TokenRule) (*Tokenizer, error) {
+	t := &Tokenizer{}
+	for _, r := range rules {
+		re, err := Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", r.Name, err)
+		}
+		t.names = append(t.names, r.Name)
+		t.res = append(t.res, re)
+	}
+	return t, nil
+}
+
+// All returns an iterator over the tokens of s. At each position the rule
+// with the longest non-empty match wins, the earliest listed on a tie, as
+// in lex. If no rule matches, or a match exceeds its budget, the iterator
+// yields the error and stops.
+func (t *Tokenizer) All(s string) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for pos := 0; pos < len(s); {
+			best, bestEnd := -1, pos
+			for i, re := range t.res {
+				end, err := re.matchHere(s, pos)
+				if err != nil {
+					yield(Token{}, fmt.Errorf("token %s at offset %d: %w", t.names[i], pos, err))
+					return
+				}
+				if end > bestEnd {
+					best, bestEnd = i, end
+				}
+			}
+			if best < 0 {
+				yield(Token{}, fmt.Errorf("%w at offset %d", ErrNoToken, pos))
+				return
+			}
+			tok := Token{Name: t.names[best], Text: s[pos:bestEnd], Start: pos, End: bestEnd}
+			if !yield(tok, nil) {
+				return
+			}
+			pos = bestEnd
+		}
+	}
+}
+
+// matchHere returns the end of the match of the pattern that starts at pos
+// in s, or -1 if there is none.
+func (re *Regexp) matchHere(s string, pos int) (int, error) {
+	m := re.get(s)
+	defer re.put(m)
+	m.memoFrom(pos)
+	if !m.matchAt(pos) {
+		return -1, m.err
+	}
+	return m.slots[1], nil
+}
@@ -0,0 +1,98 @@
+package regex
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tokens collects the tokens of s as "name:text", and the error that
+// stopped them.
+func tokens(t *testing.T, tok *Tokenizer, s string) ([]string, error) {
+	t.Helper()
+	var out []string
+	for tk, err := range tok.All(s) {
+		if err != nil {
+			return out, err
+		}
+		if s[tk.Start:tk.End] != tk.Text {
+			t.Errorf("token %q at [%d, %d) of %q", tk.Text, tk.Start, tk.End, s)
+		}
+		out = append(out, tk.Name+":"+tk.Text)
+	}
+	return out, nil
+}
+
+func TestTokenizer(t *testing.T) {
+	tok, err := NewTokenizer([]TokenRule{
+		{"if", `if`},
+		{"ident", `[a-z]+`},
+		{"num", `\d+`},
+		{"op", `[-+]`},
+		{"incr", `\+\+`},
+		{"space", `\s+`},
+		// never wins: its matches are empty
+		{"empty", `x*`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		// the longest match wins, the earliest rule on a tie
+		{"if iffy", []string{"if:if", "space: ", "ident:iffy"}},
+		{"a++1", []string{"ident:a", "incr:++", "num:1"}},
+		{"", nil},
+	} {
+		got, err := tokens(t, tok, tt.input)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	got, err := tokens(t, tok, "ab ?c")
+	if !errors.Is(err, ErrNoToken) || !strings.Contains(err.Error(), "offset 3") {
+		t.Errorf("got error %v, want ErrNoToken at offset 3", err)
+	}
+	if want := []string{"ident:ab", "space: "}; !slices.Equal(got, want) {
+		t.Errorf("before the error: got %q, want %q", got, want)
+	}
+}
+
+func TestTokenizerBudget(t *testing.T) {
+	tok := &Tokenizer{
+		names: []string{"word", "slow"},
+		res:   []*Regexp{MustCompile(`\w+ `), mustCompileOptions(t, `(a|a)*b`, Options{MaxSteps: 1000})},
+	}
+	got, err := tokens(t, tok, "ok "+strings.Repeat("a", 30))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("got error %v, want ErrBudgetExceeded", err)
+	}
+	if want := []string{"word:ok "}; !slices.Equal(got, want) {
+		t.Errorf("before the error: got %q, want %q", got, want)
+	}
+}
+
+func TestTokenizerBreak(t *testing.T) {
+	tok, err := NewTokenizer([]TokenRule{{"a", `a`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the search stops at the break, before the text no rule matches; the
+	// range would panic if the iterator went on yielding
+	n := 0
+	for _, err := range tok.All("aa?") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("got %d tokens, want 2", n)
+	}
+}